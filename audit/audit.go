@@ -0,0 +1,283 @@
+// Package audit keeps an append-only record of every mutation: who ran it, with what arguments, and what it wrote to
+// the backing APIs.
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/djspinmonkey/lightgraph-go/auth"
+	"github.com/djspinmonkey/lightgraph-go/idempotency"
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// Redacted replaces the values of secret arguments in audit records.
+const Redacted = "[REDACTED]"
+
+// Record describes a single mutation.
+type Record struct {
+	ID         string    `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Caller     string    `json:"caller"`
+	RemoteAddr string    `json:"remoteAddr,omitempty"`
+	// Operation is the name of the mutation field, like "createAlert".
+	Operation string `json:"operation"`
+	// OperationName is the name the client gave the GraphQL operation, if any.
+	OperationName  string                 `json:"operationName,omitempty"`
+	IdempotencyKey string                 `json:"idempotencyKey,omitempty"`
+	Arguments      map[string]interface{} `json:"arguments"`
+	Upstream       []*restapi.Write       `json:"upstream"`
+	Success        bool                   `json:"success"`
+	Error          *string                `json:"error,omitempty"`
+	// Replay is set when the response to an earlier request with the same idempotency key was sent back instead of
+	// running the mutation again. Success and Error then describe the replayed response.
+	Replay bool `json:"replay,omitempty"`
+}
+
+// DryRun reports whether every upstream write made by the mutation was recorded by a dry run rather than sent.
+func (r *Record) DryRun() bool {
+	for _, write := range r.Upstream {
+		if !write.DryRun {
+			return false
+		}
+	}
+
+	return len(r.Upstream) > 0
+}
+
+// Filter selects audit records. A record must match every criterion that's given.
+type Filter struct {
+	Operation *string
+	Caller    *string
+	Since     *time.Time
+	Until     *time.Time
+	Success   *bool
+	Limit     *int
+}
+
+// DefaultLimit is how many records a Filter returns if it doesn't set a limit.
+const DefaultLimit = 100
+
+// Matches reports whether the record matches every criterion in the filter.
+func (f *Filter) Matches(r *Record) bool {
+	switch {
+	case f.Operation != nil && r.Operation != *f.Operation:
+		return false
+	case f.Caller != nil && r.Caller != *f.Caller:
+		return false
+	case f.Since != nil && r.Timestamp.Before(*f.Since):
+		return false
+	case f.Until != nil && !r.Timestamp.Before(*f.Until):
+		return false
+	case f.Success != nil && r.Success != *f.Success:
+		return false
+	}
+
+	return true
+}
+
+// Sink stores audit records.
+type Sink interface {
+	// Write appends a record.
+	Write(r *Record) error
+
+	// Records returns the stored records, oldest first.
+	Records() ([]*Record, error)
+}
+
+// Log records mutations in a sink. It's a gqlgen extension, and records every top-level mutation field run by the
+// server it's installed in, as well as every mutation whose response an idempotency.Store replays. To see the
+// replays, it must be installed before the store.
+type Log struct {
+	Sink Sink
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.FieldInterceptor
+	graphql.ResponseInterceptor
+} = &Log{}
+
+// ExtensionName returns the name of the gqlgen extension.
+func (l *Log) ExtensionName() string {
+	return "AuditLog"
+}
+
+// Validate checks that the extension can be used with the schema.
+func (l *Log) Validate(schema graphql.ExecutableSchema) error {
+	if l.Sink == nil {
+		return errors.New("audit log has no sink")
+	}
+
+	return nil
+}
+
+// InterceptField resolves top-level mutation fields with a context that logs their upstream writes, then records
+// them. Failing to record a mutation is logged, but doesn't fail the mutation, which has already happened.
+func (l *Log) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || fc.Object != "Mutation" || strings.HasPrefix(fc.Field.Name, "__") {
+		return next(ctx)
+	}
+
+	writeCtx, writeLog := restapi.WithWriteLog(ctx)
+	res, err := next(writeCtx)
+
+	oc := graphql.GetOperationContext(ctx)
+	identity := auth.IdentityFrom(ctx)
+	record := &Record{
+		ID:             newID(),
+		Timestamp:      time.Now().UTC(),
+		Caller:         identity.Subject,
+		RemoteAddr:     identity.RemoteAddr,
+		Operation:      fc.Field.Name,
+		OperationName:  oc.OperationName,
+		IdempotencyKey: idempotency.KeyFrom(ctx),
+		Arguments:      redact(fc.Field.ArgumentMap(oc.Variables)),
+		Upstream:       writeLog.Writes(),
+		Success:        err == nil,
+	}
+	if err != nil {
+		message := err.Error()
+		record.Error = &message
+	} else if fieldErrors := graphql.GetFieldErrors(ctx, fc); len(fieldErrors) > 0 {
+		// A resolver can report an error alongside its result, when only part of a mutation succeeded.
+		message := fieldErrors.Error()
+		record.Success = false
+		record.Error = &message
+	}
+
+	l.write(record)
+
+	return res, err
+}
+
+// InterceptResponse records each top-level mutation field of a response that was replayed for a repeated idempotency
+// key, since the mutation isn't run again and InterceptField doesn't see it.
+func (l *Log) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	response := next(ctx)
+	if response == nil || response.Extensions[idempotency.ReplayedExtension] != true {
+		return response
+	}
+
+	oc := graphql.GetOperationContext(ctx)
+	identity := auth.IdentityFrom(ctx)
+	for _, field := range graphql.CollectFields(oc, oc.Operation.SelectionSet, []string{"Mutation"}) {
+		if strings.HasPrefix(field.Name, "__") {
+			continue
+		}
+
+		record := &Record{
+			ID:             newID(),
+			Timestamp:      time.Now().UTC(),
+			Caller:         identity.Subject,
+			RemoteAddr:     identity.RemoteAddr,
+			Operation:      field.Name,
+			OperationName:  oc.OperationName,
+			IdempotencyKey: idempotency.KeyFrom(ctx),
+			Arguments:      redact(field.ArgumentMap(oc.Variables)),
+			Upstream:       []*restapi.Write{},
+			Success:        len(response.Errors) == 0,
+			Replay:         true,
+		}
+		if len(response.Errors) > 0 {
+			message := response.Errors.Error()
+			record.Error = &message
+		}
+		l.write(record)
+	}
+
+	return response
+}
+
+// write writes the record to the sink, logging any failure.
+func (l *Log) write(record *Record) {
+	if err := l.Sink.Write(record); err != nil {
+		log.Printf("Failed to write audit record for %s: %s", record.Operation, err.Error())
+	}
+}
+
+// Query returns the records that match the filter and that visible reports the caller may see, newest first.
+func (l *Log) Query(filter Filter, visible func(*Record) bool) ([]*Record, error) {
+	records, err := l.Sink.Records()
+	if err != nil {
+		return nil, errors.New("Failed to read audit log: " + err.Error())
+	}
+
+	limit := DefaultLimit
+	if filter.Limit != nil {
+		limit = *filter.Limit
+	}
+
+	matches := []*Record{}
+	for i := len(records) - 1; i >= 0 && len(matches) < limit; i-- {
+		if filter.Matches(records[i]) && visible(records[i]) {
+			matches = append(matches, records[i])
+		}
+	}
+
+	return matches, nil
+}
+
+// secretArguments are the names of arguments whose values are always redacted, wherever they appear.
+var secretArguments = map[string]bool{
+	"integrationkey": true,
+	"password":       true,
+	"secret":         true,
+	"token":          true,
+	"apikey":         true,
+}
+
+// secretValueLists are the names of arguments holding lists of key/value pairs whose values are secret, like
+// ServiceNow auth values and webhook custom headers.
+var secretValueLists = map[string]bool{
+	"auth":          true,
+	"customheaders": true,
+}
+
+// redact returns a copy of the arguments with secret values replaced by Redacted.
+func redact(args map[string]any) map[string]any {
+	return redactValue(args, false).(map[string]any)
+}
+
+// redactValue returns a copy of the value with secrets redacted. If secretPairs is set, the value is part of a list
+// of key/value pairs whose values are secret.
+func redactValue(value any, secretPairs bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for key, child := range v {
+			name := strings.ToLower(key)
+			switch {
+			case child == nil:
+				redacted[key] = nil
+			case secretArguments[name] || (secretPairs && name == "value"):
+				redacted[key] = Redacted
+			default:
+				redacted[key] = redactValue(child, secretValueLists[name])
+			}
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, child := range v {
+			redacted[i] = redactValue(child, secretPairs)
+		}
+		return redacted
+	default:
+		return v
+	}
+}
+
+// newID returns a random ID for a record.
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
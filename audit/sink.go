@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// MaxMemoryRecords is how many of the latest records a sink keeps in memory for querying.
+const MaxMemoryRecords = 10000
+
+// remember adds a record to a sink's latest records, dropping the oldest beyond MaxMemoryRecords.
+func remember(records []*Record, r *Record) []*Record {
+	records = append(records, r)
+	if len(records) > MaxMemoryRecords {
+		records = records[len(records)-MaxMemoryRecords:]
+	}
+
+	return records
+}
+
+// WriterSink writes each record as a line of JSON to a writer, like os.Stdout. Since what's written can't be read
+// back, it also keeps the last MaxMemoryRecords records in memory for querying.
+type WriterSink struct {
+	Out io.Writer
+
+	mu      sync.Mutex
+	records []*Record
+}
+
+// Write writes the record to the sink's writer and remembers it.
+func (s *WriterSink) Write(r *Record) error {
+	encoded, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = remember(s.records, r)
+
+	_, err = s.Out.Write(append(encoded, '\n'))
+	return err
+}
+
+// Records returns the records written since the server started, up to MaxMemoryRecords.
+func (s *WriterSink) Records() ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Record{}, s.records...), nil
+}
+
+// FileSink appends each record as a line of JSON to a file, creating it if it doesn't exist. It keeps the last
+// MaxMemoryRecords records in memory for querying, read from the file the first time they're needed, so the file
+// is only read once. It expects to be the file's only writer.
+type FileSink struct {
+	Path string
+
+	mu      sync.Mutex
+	loaded  bool
+	records []*Record
+}
+
+// Write appends the record to the file and remembers it.
+func (s *FileSink) Write(r *Record) error {
+	encoded, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Read what's already in the file first, so that the remembered records stay in order.
+	err = s.load()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(encoded, '\n'))
+	if err != nil {
+		return err
+	}
+	s.records = remember(s.records, r)
+
+	return nil
+}
+
+// Records returns the last MaxMemoryRecords records in the file.
+func (s *FileSink) Records() ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*Record{}, s.records...), nil
+}
+
+// load reads the last MaxMemoryRecords records in the file, if they haven't been read yet. Lines that can't be
+// parsed, like one cut short by a crash, are skipped. The caller must hold s.mu.
+func (s *FileSink) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	f, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var records []*Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("Skipping unreadable audit record on line %d of %s: %s", line, s.Path, err.Error())
+			continue
+		}
+		records = remember(records, &record)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	s.records = records
+	s.loaded = true
+	return nil
+}
+
+// SinkFromSpec returns the sink described by spec: "stdout", or the path of a JSONL file.
+func SinkFromSpec(spec string) Sink {
+	if spec == "" || spec == "stdout" {
+		return &WriterSink{Out: os.Stdout}
+	}
+
+	return &FileSink{Path: spec}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// APIKeyHeader is the request header that carries a static API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKey is a static API key and the identity it proves.
+type APIKey struct {
+	// Name identifies the key's holder, and is the subject of callers using it.
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
+	Groups []string `json:"groups"`
+}
+
+// APIKeys authenticates callers by a static API key in the X-API-Key header.
+type APIKeys struct {
+	Keys []APIKey
+}
+
+// LoadAPIKeys reads a list of API keys from a JSON file, like
+// [{"name": "noc-wall", "key": "...", "scopes": ["secrets:read"], "groups": ["sre"]}].
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("Failed to read API keys: " + err.Error())
+	}
+
+	var keys []APIKey
+	err = json.Unmarshal(contents, &keys)
+	if err != nil {
+		return nil, errors.New("Failed to parse API keys: " + err.Error())
+	}
+	for _, key := range keys {
+		if key.Name == "" || key.Key == "" {
+			return nil, errors.New("every API key needs a name and a key")
+		}
+	}
+
+	return &APIKeys{Keys: keys}, nil
+}
+
+// Authenticate returns the identity of the API key in the request's X-API-Key header.
+func (a *APIKeys) Authenticate(r *http.Request) (*Identity, error) {
+	presented := r.Header.Get(APIKeyHeader)
+	if presented == "" {
+		return nil, ErrNoCredentials
+	}
+
+	// Compare digests, so that neither the comparison nor the keys' lengths give anything away through timing.
+	digest := sha256.Sum256([]byte(presented))
+	var match *APIKey
+	for i, key := range a.Keys {
+		keyDigest := sha256.Sum256([]byte(key.Key))
+		if subtle.ConstantTimeCompare(digest[:], keyDigest[:]) == 1 {
+			match = &a.Keys[i]
+		}
+	}
+	if match == nil {
+		return nil, errors.New("invalid API key")
+	}
+
+	return &Identity{Subject: match.Name, Scopes: append([]string{}, match.Scopes...), Groups: append([]string{}, match.Groups...), Method: "apikey"}, nil
+}
@@ -0,0 +1,158 @@
+// Package auth identifies the caller of each request.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// Anonymous is the subject of callers who haven't identified themselves.
+const Anonymous = "anonymous"
+
+// SecretsRead is the scope that lets a caller see the values of @sensitive fields, like API keys and integration keys.
+const SecretsRead = "secrets:read"
+
+// Identity describes the caller of a request.
+type Identity struct {
+	// Subject identifies the caller, like a user name or API key name.
+	Subject string
+	// RemoteAddr is the network address the request came from.
+	RemoteAddr string
+	// Scopes are the permissions granted to the caller, like SecretsRead.
+	Scopes []string
+	// Groups are the groups the caller belongs to, like teams, which authorization policies can grant access to.
+	Groups []string
+	// Method is how the caller proved who they are, like "jwt" or "apikey", or empty for anonymous callers.
+	Method string
+	// Claims are the claims of the caller's token, if they presented one.
+	Claims map[string]interface{}
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying the caller's identity.
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFrom returns the caller's identity carried by the context, or an anonymous identity if there isn't one.
+func IdentityFrom(ctx context.Context) *Identity {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	if !ok {
+		return &Identity{Subject: Anonymous}
+	}
+
+	return identity
+}
+
+// HasScope reports whether the caller has been granted the scope.
+func (i *Identity) HasScope(scope string) bool {
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ErrNoCredentials is returned by an Authenticator when the request doesn't carry the kind of credentials it checks.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Authenticator identifies the caller of a request from the credentials it carries.
+type Authenticator interface {
+	// Authenticate returns the identity proved by the request's credentials. It returns ErrNoCredentials if the
+	// request doesn't carry any credentials of its kind, and another error if the credentials it carries are invalid.
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Authentication requires callers to prove who they are to one of its authenticators. With no authenticators, every
+// caller is anonymous.
+type Authentication struct {
+	Authenticators []Authenticator
+}
+
+// Required reports whether callers must authenticate.
+func (a *Authentication) Required() bool {
+	return len(a.Authenticators) > 0
+}
+
+// authenticate asks each authenticator in turn for the caller's identity, stopping at the first one that finds
+// credentials of its kind.
+func (a *Authentication) authenticate(r *http.Request) (*Identity, error) {
+	for _, authenticator := range a.Authenticators {
+		identity, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		identity.RemoteAddr = r.RemoteAddr
+		return identity, nil
+	}
+
+	return nil, ErrNoCredentials
+}
+
+// Middleware adds the caller's identity to the request context, rejecting requests with missing or invalid
+// credentials. Websocket upgrades without credentials are let through, since browsers can't add headers to them;
+// WebsocketInit then authenticates the connection from its init payload instead.
+func (a *Authentication) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Required() || r.Method == http.MethodOptions {
+			identity := &Identity{Subject: Anonymous, RemoteAddr: r.RemoteAddr}
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+			return
+		}
+
+		identity, err := a.authenticate(r)
+		if errors.Is(err, ErrNoCredentials) && isWebsocketUpgrade(r) {
+			identity, err = &Identity{Subject: Anonymous, RemoteAddr: r.RemoteAddr}, nil
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+	})
+}
+
+// WebsocketInit authenticates a websocket connection whose upgrade request didn't carry credentials, using the
+// "Authorization" and "X-API-Key" values of its init payload in place of headers. It's meant to be the InitFunc of
+// the websocket transport.
+func (a *Authentication) WebsocketInit(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	current := IdentityFrom(ctx)
+	if !a.Required() || current.Method != "" {
+		return ctx, nil, nil
+	}
+
+	r := &http.Request{Header: make(http.Header), RemoteAddr: current.RemoteAddr}
+	if authorization := payload.Authorization(); authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	for key := range payload {
+		if strings.EqualFold(key, APIKeyHeader) {
+			r.Header.Set(APIKeyHeader, payload.GetString(key))
+		}
+	}
+
+	identity, err := a.authenticate(r)
+	if err != nil {
+		return ctx, nil, errors.New("Unauthorized: " + err.Error())
+	}
+
+	return WithIdentity(ctx, identity), nil, nil
+}
+
+// isWebsocketUpgrade reports whether the request asks to upgrade the connection to a websocket.
+func isWebsocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
@@ -0,0 +1,311 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// JWKSRefreshInterval is how often a JWKS is reloaded, to pick up rotated keys.
+	JWKSRefreshInterval = time.Hour
+
+	// JWKSMinRefreshInterval is the least time between reloads of a JWKS prompted by tokens signed with unknown keys,
+	// so that a flood of such tokens doesn't become a flood of requests to the identity provider.
+	JWKSMinRefreshInterval = time.Minute
+)
+
+// JsonShapedJWKS is an intermediate representation of a JSON Web Key Set.
+type JsonShapedJWKS struct {
+	Keys []JsonShapedJWK `json:"keys"`
+}
+
+// JsonShapedJWK is an intermediate representation of a single JSON Web Key.
+type JsonShapedJWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+}
+
+// signingKey is a public key from a JWKS that tokens can be verified with.
+type signingKey struct {
+	id string
+	// algorithm is the algorithm the key is restricted to, or empty if it isn't.
+	algorithm string
+	public    crypto.PublicKey
+}
+
+// JWKS is a JSON Web Key Set loaded from a file or an http(s) URL, and reloaded every JWKSRefreshInterval. It's safe
+// for concurrent use.
+type JWKS struct {
+	// Source is the path or URL the key set is loaded from.
+	Source string
+
+	mu   sync.Mutex
+	keys []*signingKey
+	// loadedAt is when the last reload started.
+	loadedAt time.Time
+	// reloading is the reload in progress, if there is one.
+	reloading *jwksReload
+}
+
+// jwksReload is a reload of a JWKS, which everyone who needs it to finish waits for.
+type jwksReload struct {
+	done chan struct{}
+	// err is the reload's error, set before done is closed.
+	err error
+}
+
+// LoadJWKS loads the key set at the source, a file path or an http(s) URL.
+func LoadJWKS(source string) (*JWKS, error) {
+	jwks := &JWKS{Source: source}
+
+	jwks.mu.Lock()
+	reload := jwks.reloadLocked()
+	jwks.mu.Unlock()
+
+	<-reload.done
+	if reload.err != nil {
+		return nil, reload.err
+	}
+
+	return jwks, nil
+}
+
+// keysFor returns the keys that a token with the given key ID and algorithm could have been signed with. The key set
+// is reloaded if it's stale, or if none of its keys match and it hasn't been reloaded recently. A stale key set goes
+// on being used while it's reloaded; only a token that none of its keys match waits for the reload.
+func (j *JWKS) keysFor(keyID string, algorithm string) []*signingKey {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if time.Since(j.loadedAt) >= JWKSRefreshInterval {
+		j.reloadLocked()
+	}
+
+	matches := j.matching(keyID, algorithm)
+	if len(matches) > 0 {
+		return matches
+	}
+
+	reload := j.reloading
+	if reload == nil && time.Since(j.loadedAt) >= JWKSMinRefreshInterval {
+		reload = j.reloadLocked()
+	}
+	if reload != nil {
+		j.mu.Unlock()
+		<-reload.done
+		j.mu.Lock()
+		matches = j.matching(keyID, algorithm)
+	}
+
+	return matches
+}
+
+// matching returns the loaded keys with the key ID, or all of them if it's empty, that can verify the algorithm. The
+// caller must hold j.mu.
+func (j *JWKS) matching(keyID string, algorithm string) []*signingKey {
+	var matches []*signingKey
+	for _, key := range j.keys {
+		if keyID != "" && key.id != keyID {
+			continue
+		}
+		if key.algorithm != "" && key.algorithm != algorithm {
+			continue
+		}
+		if !keyFits(key.public, algorithm) {
+			continue
+		}
+		matches = append(matches, key)
+	}
+
+	return matches
+}
+
+// reloadLocked starts reloading the key set in the background, unless a reload is already in progress, and returns
+// the reload. The caller must hold j.mu.
+func (j *JWKS) reloadLocked() *jwksReload {
+	if j.reloading == nil {
+		j.reloading = &jwksReload{done: make(chan struct{})}
+		j.loadedAt = time.Now()
+		go j.load(j.reloading)
+	}
+
+	return j.reloading
+}
+
+// load loads the key set from its source, without holding j.mu while it does, and finishes the reload. The keys it
+// had are kept if that fails.
+func (j *JWKS) load(reload *jwksReload) {
+	keys, err := j.fetch()
+
+	j.mu.Lock()
+	if err == nil {
+		j.keys = keys
+	}
+	j.reloading = nil
+	j.mu.Unlock()
+
+	reload.err = err
+	close(reload.done)
+}
+
+// fetch reads and parses the key set from its source.
+func (j *JWKS) fetch() ([]*signingKey, error) {
+	contents, err := j.read()
+	if err != nil {
+		log.Printf("Failed to load JWKS from %s: %s", j.Source, err.Error())
+		return nil, errors.New("Failed to load JWKS: " + err.Error())
+	}
+
+	keys, err := parseJWKS(contents)
+	if err != nil {
+		log.Printf("Failed to parse JWKS from %s: %s", j.Source, err.Error())
+		return nil, errors.New("Failed to parse JWKS: " + err.Error())
+	}
+
+	return keys, nil
+}
+
+// read returns the contents of the key set's source.
+func (j *JWKS) read() ([]byte, error) {
+	if !strings.HasPrefix(j.Source, "http://") && !strings.HasPrefix(j.Source, "https://") {
+		return os.ReadFile(j.Source)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(j.Source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseJWKS returns the signing keys in a JSON Web Key Set. Keys meant for encryption, and keys of types that can't
+// verify any supported algorithm, are skipped.
+func parseJWKS(contents []byte) ([]*signingKey, error) {
+	var jwks JsonShapedJWKS
+	err := json.Unmarshal(contents, &jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*signingKey
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		public, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %s", jwk.KeyID, err.Error())
+		}
+		if public == nil {
+			continue
+		}
+
+		keys = append(keys, &signingKey{id: jwk.KeyID, algorithm: jwk.Algorithm, public: public})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+
+	return keys, nil
+}
+
+// publicKey returns the public key the JWK describes, or nil if it's of an unsupported type.
+func (jwk JsonShapedJWK) publicKey() (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, errors.New("invalid modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid exponent")
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		var checker ecdh.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve, checker = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, checker = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, checker = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, nil
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, errors.New("invalid x coordinate")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, errors.New("invalid y coordinate")
+		}
+
+		// Make sure the point is on the curve before trusting it.
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) > size || len(y) > size {
+			return nil, errors.New("coordinates too long for curve")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(x):1+size], x)
+		copy(point[1+2*size-len(y):], y)
+		if _, err := checker.NewPublicKey(point); err != nil {
+			return nil, errors.New("point isn't on the curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, nil
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, nil
+}
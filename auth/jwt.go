@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ClockSkew is how far a token's expiry and not-before times may be overstepped, to allow for clocks that disagree.
+const ClockSkew = time.Minute
+
+// JsonShapedJWTHeader is an intermediate representation of the header of a JWT.
+type JsonShapedJWTHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// JWT authenticates callers by a JWT bearer token in the Authorization header, signed by a key in the JWKS. Tokens
+// must have an expiry, and if Issuer or Audience is set, must have been issued by it or for it.
+type JWT struct {
+	JWKS     *JWKS
+	Issuer   string
+	Audience string
+}
+
+// Authenticate returns the identity of the subject of the request's bearer token.
+func (j *JWT) Authenticate(r *http.Request) (*Identity, error) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := j.verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, errors.New("invalid bearer token: " + err.Error())
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		// Tokens issued by the client credentials flow may identify the client rather than a subject.
+		subject, _ = claims["client_id"].(string)
+	}
+	if subject == "" {
+		return nil, errors.New("invalid bearer token: no subject")
+	}
+
+	return &Identity{Subject: subject, Scopes: scopesOf(claims), Groups: groupsOf(claims), Method: "jwt", Claims: claims}, nil
+}
+
+// verify checks the token's signature and registered claims, and returns its claims.
+func (j *JWT) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("not a JWT")
+	}
+
+	var header JsonShapedJWTHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, errors.New("malformed header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range j.JWKS.keysFor(header.KeyID, header.Algorithm) {
+		if verifySignature(header.Algorithm, key.public, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("signature doesn't match a trusted key")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed claims")
+	}
+	var claims map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	err = decoder.Decode(&claims)
+	if err != nil {
+		return nil, errors.New("malformed claims")
+	}
+
+	now := time.Now()
+	expiry, ok := numericDate(claims["exp"])
+	if !ok {
+		return nil, errors.New("no expiry")
+	}
+	if now.After(expiry.Add(ClockSkew)) {
+		return nil, errors.New("expired")
+	}
+	if notBefore, ok := numericDate(claims["nbf"]); ok && now.Add(ClockSkew).Before(notBefore) {
+		return nil, errors.New("not valid yet")
+	}
+	if j.Issuer != "" && claims["iss"] != j.Issuer {
+		return nil, errors.New("unexpected issuer")
+	}
+	if j.Audience != "" && !hasAudience(claims["aud"], j.Audience) {
+		return nil, errors.New("unexpected audience")
+	}
+
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url-encoded JSON segment of a JWT into v.
+func decodeSegment(segment string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(decoded, v)
+}
+
+// hashes are the hash functions of the supported signing algorithms.
+var hashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// keyFits reports whether the key is of the type the algorithm signs with. Unsupported algorithms, including "none"
+// and the HMAC ones, fit no key.
+func keyFits(public crypto.PublicKey, algorithm string) bool {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(algorithm, "RS") || strings.HasPrefix(algorithm, "PS")
+	case *ecdsa.PublicKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return algorithm == "ES256"
+		case 384:
+			return algorithm == "ES384"
+		case 521:
+			return algorithm == "ES512"
+		}
+	case ed25519.PublicKey:
+		return algorithm == "EdDSA"
+	}
+
+	return false
+}
+
+// verifySignature reports whether the signature is a valid signature of the signed bytes by the key, using the
+// algorithm.
+func verifySignature(algorithm string, public crypto.PublicKey, signed []byte, signature []byte) bool {
+	if key, ok := public.(ed25519.PublicKey); ok {
+		return algorithm == "EdDSA" && ed25519.Verify(key, signed, signature)
+	}
+
+	hash, ok := hashes[algorithm]
+	if !ok {
+		return false
+	}
+	hasher := hash.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(algorithm, "PS") {
+			return rsa.VerifyPSS(key, hash, digest, signature, nil) == nil
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS signatures are the fixed-size big-endian r and s, one after the other, rather than ASN.1.
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+
+	return false
+}
+
+// numericDate returns the time of a NumericDate claim, like "exp", and whether the claim is present and valid.
+func numericDate(claim interface{}) (time.Time, bool) {
+	number, ok := claim.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// hasAudience reports whether an "aud" claim, a string or list of strings, includes the audience.
+func hasAudience(claim interface{}, audience string) bool {
+	switch aud := claim.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// scopesOf returns the scopes granted by a token: the space-separated "scope" claim of OAuth 2.0, or the "scp" claim
+// some providers use instead, either a list or a space-separated string.
+func scopesOf(claims map[string]interface{}) []string {
+	scopes := []string{}
+	for _, name := range []string{"scope", "scp"} {
+		switch claim := claims[name].(type) {
+		case string:
+			scopes = append(scopes, strings.Fields(claim)...)
+		case []interface{}:
+			for _, scope := range claim {
+				if s, ok := scope.(string); ok {
+					scopes = append(scopes, s)
+				}
+			}
+		}
+	}
+
+	return scopes
+}
+
+// groupsOf returns the groups listed in a token's "groups" claim.
+func groupsOf(claims map[string]interface{}) []string {
+	groups := []string{}
+	if claim, ok := claims["groups"].([]interface{}); ok {
+		for _, group := range claim {
+			if g, ok := group.(string); ok {
+				groups = append(groups, g)
+			}
+		}
+	}
+
+	return groups
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testKeys are the private keys test tokens are signed with.
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testKeys{rsa: rsaKey, ec: ecKey, ed25519: edKey}
+}
+
+// jwks returns a key set trusting the keys: "rsa" for any RSA algorithm, "rsa-rs256" restricted to RS256, "ec" and
+// "ed".
+func (k *testKeys) jwks() *JWKS {
+	return &JWKS{
+		keys: []*signingKey{
+			{id: "rsa", public: &k.rsa.PublicKey},
+			{id: "rsa-rs256", algorithm: "RS256", public: &k.rsa.PublicKey},
+			{id: "ec", public: &k.ec.PublicKey},
+			{id: "ed", public: k.ed25519.Public()},
+		},
+		loadedAt: time.Now(),
+	}
+}
+
+// sign returns a token with the header and claims, signed with the algorithm using the matching test key. Algorithms
+// the verifier doesn't support are signed too, the way an attacker would: "none" with an empty signature, and HS256
+// with the RSA public key as the HMAC secret.
+func (k *testKeys) sign(t *testing.T, header map[string]any, claims map[string]any) string {
+	t.Helper()
+
+	encode := func(v any) string {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(encoded)
+	}
+	signed := encode(header) + "." + encode(claims)
+
+	var signature []byte
+	var err error
+	algorithm, _ := header["alg"].(string)
+	switch algorithm {
+	case "none":
+	case "HS256":
+		secret, _ := x509.MarshalPKIXPublicKey(&k.rsa.PublicKey)
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case "RS256":
+		digest := sha256.Sum256([]byte(signed))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+	case "PS256":
+		digest := sha256.Sum256([]byte(signed))
+		signature, err = rsa.SignPSS(rand.Reader, k.rsa, crypto.SHA256, digest[:], nil)
+	case "ES256":
+		digest := sha256.Sum256([]byte(signed))
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err == nil {
+			signature = make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+		}
+	case "EdDSA":
+		signature = ed25519.Sign(k.ed25519, []byte(signed))
+	default:
+		t.Fatalf("can't sign with %s", algorithm)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims returns claims that pass every check of a JWT with issuer "https://idp" and audience "lightgraph".
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub": "alice",
+		"iss": "https://idp",
+		"aud": "lightgraph",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// with returns a copy of the claims with the given claims changed, or removed if their value is nil.
+func with(claims map[string]any, changes map[string]any) map[string]any {
+	changed := make(map[string]any, len(claims))
+	for name, value := range claims {
+		changed[name] = value
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(changed, name)
+		} else {
+			changed[name] = value
+		}
+	}
+
+	return changed
+}
+
+func TestVerify(t *testing.T) {
+	keys := newTestKeys(t)
+	j := &JWT{JWKS: keys.jwks(), Issuer: "https://idp", Audience: "lightgraph"}
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		header  map[string]any
+		claims  map[string]any
+		wantErr string
+	}{
+		{"RS256", map[string]any{"alg": "RS256", "kid": "rsa"}, validClaims(), ""},
+		{"PS256", map[string]any{"alg": "PS256", "kid": "rsa"}, validClaims(), ""},
+		{"ES256", map[string]any{"alg": "ES256", "kid": "ec"}, validClaims(), ""},
+		{"EdDSA", map[string]any{"alg": "EdDSA", "kid": "ed"}, validClaims(), ""},
+		{"no key ID", map[string]any{"alg": "ES256"}, validClaims(), ""},
+
+		// Algorithm and key mismatches.
+		{"RSA algorithm with EC key", map[string]any{"alg": "RS256", "kid": "ec"}, validClaims(), "signature doesn't match a trusted key"},
+		{"algorithm the key is restricted against", map[string]any{"alg": "PS256", "kid": "rsa-rs256"}, validClaims(), "signature doesn't match a trusted key"},
+		{"unknown key ID", map[string]any{"alg": "RS256", "kid": "other"}, validClaims(), "signature doesn't match a trusted key"},
+		{"none", map[string]any{"alg": "none", "kid": "rsa"}, validClaims(), "signature doesn't match a trusted key"},
+		{"none without key ID", map[string]any{"alg": "none"}, validClaims(), "signature doesn't match a trusted key"},
+		{"HS256 keyed with the public key", map[string]any{"alg": "HS256", "kid": "rsa"}, validClaims(), "signature doesn't match a trusted key"},
+
+		// Expiry and not-before, with clock skew.
+		{"no expiry", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"exp": nil}), "no expiry"},
+		{"expired", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"exp": now.Add(-ClockSkew - time.Minute).Unix()}), "expired"},
+		{"expired within skew", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"exp": now.Add(-ClockSkew / 2).Unix()}), ""},
+		{"not valid yet", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"nbf": now.Add(ClockSkew + time.Minute).Unix()}), "not valid yet"},
+		{"not valid yet within skew", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"nbf": now.Add(ClockSkew / 2).Unix()}), ""},
+		{"expiry as a string", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"exp": fmt.Sprint(now.Add(time.Hour).Unix())}), "no expiry"},
+
+		// Issuer and audience.
+		{"wrong issuer", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"iss": "https://evil"}), "unexpected issuer"},
+		{"no issuer", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"iss": nil}), "unexpected issuer"},
+		{"wrong audience", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"aud": "other"}), "unexpected audience"},
+		{"no audience", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"aud": nil}), "unexpected audience"},
+		{"audience in a list", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"aud": []string{"other", "lightgraph"}}), ""},
+		{"audience not in a list", map[string]any{"alg": "RS256", "kid": "rsa"}, with(validClaims(), map[string]any{"aud": []string{"other"}}), "unexpected audience"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := j.verify(keys.sign(t, tt.header, tt.claims))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("verify() returned error %q, want none", err.Error())
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("verify() returned no error, want %q", tt.wantErr)
+			case tt.wantErr != "" && err.Error() != tt.wantErr:
+				t.Errorf("verify() returned error %q, want %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsTamperedClaims(t *testing.T) {
+	keys := newTestKeys(t)
+	j := &JWT{JWKS: keys.jwks()}
+
+	parts := strings.Split(keys.sign(t, map[string]any{"alg": "ES256", "kid": "ec"}, validClaims()), ".")
+	tampered, _ := json.Marshal(with(validClaims(), map[string]any{"sub": "mallory"}))
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString(tampered) + "." + parts[2]
+
+	if _, err := j.verify(forged); err == nil {
+		t.Error("verify() accepted a token whose claims were changed after signing")
+	}
+}
+
+// TestKeysForDoesNotBlockOnReload checks that a stale key set goes on verifying tokens with known keys while it's
+// reloaded, and that a token signed with an unknown key waits for the reload and sees the new keys.
+func TestKeysForDoesNotBlockOnReload(t *testing.T) {
+	keys := newTestKeys(t)
+
+	release := make(chan struct{})
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		x := base64.RawURLEncoding.EncodeToString(keys.ed25519.Public().(ed25519.PublicKey))
+		fmt.Fprintf(w, `{"keys": [{"kty": "OKP", "crv": "Ed25519", "kid": "new", "x": %q}]}`, x)
+	}))
+	defer server.Close()
+
+	j := keys.jwks()
+	j.Source = server.URL
+	j.loadedAt = time.Now().Add(-JWKSRefreshInterval)
+
+	known := make(chan []*signingKey)
+	go func() { known <- j.keysFor("rsa", "RS256") }()
+	select {
+	case matches := <-known:
+		if len(matches) != 1 {
+			t.Fatalf("keysFor() returned %d keys for a known key ID during a reload, want 1", len(matches))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("keysFor() blocked on the reload for a known key ID")
+	}
+
+	unknown := make(chan []*signingKey)
+	go func() { unknown <- j.keysFor("new", "EdDSA") }()
+	select {
+	case <-unknown:
+		t.Fatal("keysFor() returned for an unknown key ID before the reload finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case matches := <-unknown:
+		if len(matches) != 1 {
+			t.Errorf("keysFor() returned %d keys for the reloaded key ID, want 1", len(matches))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("keysFor() didn't return after the reload finished")
+	}
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("key set was fetched %d times, want 1", n)
+	}
+}
@@ -0,0 +1,180 @@
+// Package cors decides which web pages on other origins may call the server, following the CORS protocol.
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// DefaultHeaders are the request headers allowed when none are configured: the ones the server reads.
+	DefaultHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "Idempotency-Key"}
+
+	// DefaultMethods are the request methods allowed when none are configured.
+	DefaultMethods = []string{http.MethodGet, http.MethodPost}
+)
+
+// DefaultMaxAge is how long browsers may cache a preflight response when no max age is configured.
+const DefaultMaxAge = 10 * time.Minute
+
+// Policy is the set of cross-origin requests the server accepts. Requests from origins it doesn't allow get no CORS
+// headers, so browsers don't let the calling page see the response.
+type Policy struct {
+	// Origins are the allowed origins, like "https://noc.example.com". An origin's host may start with a wildcard
+	// label, like "https://*.example.com", to allow its subdomains, and "*" allows every origin.
+	Origins []string
+	// Headers are the request headers callers may send.
+	Headers []string
+	// Methods are the request methods callers may use.
+	Methods []string
+	// Credentials allows requests with credentials, like cookies and TLS client certificates. It can't be combined
+	// with allowing every origin.
+	Credentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// New returns a policy allowing the origins, with the default headers, methods and max age. It returns an error if
+// an origin isn't a valid origin or pattern, or if credentials are allowed from every origin.
+func New(origins []string, credentials bool) (*Policy, error) {
+	for _, origin := range origins {
+		if origin == "*" {
+			if credentials {
+				return nil, errors.New("credentials can't be allowed from every origin")
+			}
+			continue
+		}
+
+		scheme, host, found := strings.Cut(origin, "://")
+		if !found || scheme == "" || host == "" || strings.ContainsAny(host, "/?#") || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			return nil, errors.New("invalid CORS origin " + strconv.Quote(origin))
+		}
+	}
+
+	return &Policy{
+		Origins:     origins,
+		Headers:     DefaultHeaders,
+		Methods:     DefaultMethods,
+		Credentials: credentials,
+		MaxAge:      DefaultMaxAge,
+	}, nil
+}
+
+// AllowsOrigin reports whether the policy allows requests from the origin.
+func (p *Policy) AllowsOrigin(origin string) bool {
+	scheme, host, found := strings.Cut(strings.ToLower(origin), "://")
+	if !found {
+		return false
+	}
+
+	for _, allowed := range p.Origins {
+		if allowed == "*" {
+			return true
+		}
+
+		allowedScheme, allowedHost, _ := strings.Cut(strings.ToLower(allowed), "://")
+		if scheme != allowedScheme {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(allowedHost, "*"); ok {
+			// The wildcard stands for one or more whole labels, so "*.example.com" doesn't allow example.com itself.
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if host == allowedHost {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckOrigin reports whether a websocket upgrade request may be accepted: requests without an Origin header, which
+// don't come from browsers, requests from the server's own origin, and requests from allowed origins. It's meant to
+// be the CheckOrigin of a websocket.Upgrader.
+func (p *Policy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	return p.AllowsOrigin(origin)
+}
+
+// Middleware adds CORS headers to responses to allowed origins, and answers preflight requests itself.
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Responses differ by origin, so caches mustn't give one origin's response to another.
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			if origin != "" && p.AllowsOrigin(origin) {
+				p.preflight(w, r, origin)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if origin != "" && p.AllowsOrigin(origin) {
+			p.allowOrigin(w, origin)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preflight adds the headers that let the browser make the request it's asking about, if the request's method and
+// headers are allowed.
+func (p *Policy) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	method := r.Header.Get("Access-Control-Request-Method")
+	if !contains(p.Methods, method) {
+		return
+	}
+
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !contains(p.Headers, header) {
+			return
+		}
+	}
+
+	p.allowOrigin(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.Methods, ", "))
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.Headers, ", "))
+	if p.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+	}
+}
+
+// allowOrigin adds the headers that let the origin see the response.
+func (p *Policy) allowOrigin(w http.ResponseWriter, origin string) {
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if p.Credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// contains reports whether the list contains the value, ignoring case, as header names and methods are compared.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/djspinmonkey/lightgraph-go/auth"
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// Masked replaces the value of a @sensitive field for callers who aren't allowed to see it.
+const Masked = "********"
+
+// DryRunExtension is the key of the response extension that @dryRun reports are added to.
+const DryRunExtension = "dryRun"
+
+// DryRunReport describes what a field marked with @dryRun would have done.
+type DryRunReport struct {
+	// Path is the path of the field in the response, like "createAlert".
+	Path     string                    `json:"path"`
+	Requests []*restapi.PlannedRequest `json:"requests"`
+	// Valid is false if the field returned an error, such as a validation failure, in which case Error describes it.
+	Valid bool    `json:"valid"`
+	Error *string `json:"error,omitempty"`
+}
+
+// dryRunReports collects the reports of every @dryRun field in a response. It's registered as the response extension
+// by the first of them, since an extension can only be registered once.
+type dryRunReports struct {
+	mu      sync.Mutex
+	reports []*DryRunReport
+}
+
+// dryRunReportsMu guards registering the dryRunReports extension.
+var dryRunReportsMu sync.Mutex
+
+// MarshalJSON encodes the reports as a list.
+func (r *dryRunReports) MarshalJSON() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return json.Marshal(r.reports)
+}
+
+// DryRun implements the @dryRun directive. It resolves the field with a dry-run context, so that writes are recorded
+// rather than sent, and reports the recorded writes in the response extensions.
+func DryRun(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	dryRunCtx, dryRun := restapi.WithDryRun(ctx)
+	res, err := next(dryRunCtx)
+
+	report := &DryRunReport{
+		Path:     graphql.GetFieldContext(ctx).Path().String(),
+		Requests: dryRun.Requests(),
+		Valid:    err == nil,
+	}
+	if err != nil {
+		message := err.Error()
+		report.Error = &message
+	}
+
+	dryRunReportsMu.Lock()
+	reports, ok := graphql.GetExtension(ctx, DryRunExtension).(*dryRunReports)
+	if !ok {
+		reports = &dryRunReports{}
+		graphql.RegisterExtension(ctx, DryRunExtension, reports)
+	}
+	dryRunReportsMu.Unlock()
+
+	reports.mu.Lock()
+	reports.reports = append(reports.reports, report)
+	reports.mu.Unlock()
+
+	return res, err
+}
+
+// Sensitive implements the @sensitive directive. Callers without the secrets:read scope get Masked in place of the
+// field's value, unless the value is empty. Every time a caller with the scope is shown a value, it's logged.
+func Sensitive(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	res, err := next(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	identity := auth.IdentityFrom(ctx)
+	if identity.HasScope(auth.SecretsRead) {
+		if res != "" && res != (*string)(nil) {
+			log.Printf("Revealed %s to %s (%s)", graphql.GetFieldContext(ctx).Path(), identity.Subject, identity.RemoteAddr)
+		}
+		return res, nil
+	}
+
+	switch value := res.(type) {
+	case string:
+		if value != "" {
+			return Masked, nil
+		}
+	case *string:
+		if value != nil && *value != "" {
+			masked := Masked
+			return &masked, nil
+		}
+	}
+
+	return res, nil
+}
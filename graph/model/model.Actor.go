@@ -1,6 +1,9 @@
 package model
 
 import (
+	"context"
+
+	"github.com/djspinmonkey/lightgraph-go/auth"
 	"github.com/djspinmonkey/lightgraph-go/restapi"
 )
 
@@ -17,3 +20,33 @@ func (a Actor) APIKey() (string, error) {
 func (a Actor) Test() (string, error) {
 	return "You have successfully queried this test field!", nil
 }
+
+// Subject identifies the caller, like the subject of their token or the name of their API key.
+func (a Actor) Subject(ctx context.Context) string {
+	return auth.IdentityFrom(ctx).Subject
+}
+
+// AuthenticationMethod is how the caller proved who they are, or nil if they're anonymous.
+func (a Actor) AuthenticationMethod(ctx context.Context) *string {
+	method := auth.IdentityFrom(ctx).Method
+	if method == "" {
+		return nil
+	}
+
+	return &method
+}
+
+// Scopes are the permissions granted to the caller.
+func (a Actor) Scopes(ctx context.Context) []string {
+	scopes := auth.IdentityFrom(ctx).Scopes
+	if scopes == nil {
+		return []string{}
+	}
+
+	return scopes
+}
+
+// Claims are the claims of the caller's token, or nil if they didn't present one.
+func (a Actor) Claims(ctx context.Context) map[string]interface{} {
+	return auth.IdentityFrom(ctx).Claims
+}
@@ -2,14 +2,14 @@ package model
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"strings"
+	"time"
 
 	"github.com/djspinmonkey/lightgraph-go/restapi"
 )
 
-// TODO: Add query strings to the Alert struct.
 // TODO: Handle composite alerts.
 
 const UnknownStatus = "unknown"
@@ -20,15 +20,23 @@ type Alert struct {
 	Name                 string
 	Description          string
 	Labels               []*Label
+	Queries              []*AlertQuery
 	EnableNoDataAlert    bool
 	EnableNoDataDuration int
 	Operand              string
-	WarningThreshold     float64
-	CriticalThreshold    float64
+	WarningThreshold     *float64
+	CriticalThreshold    *float64
 	status               string
 	Project              *Project
 	AlertingRules        []*AlertingRule
 	snoozification       *Snoozification
+	ciAssociations       *ciAssociations
+}
+
+// ciAssociations holds the CIs associated with an alert, along with any labels that couldn't be associated.
+type ciAssociations struct {
+	ciIdentifiers []*CIIdentifier
+	errors        []*CIAssociationError
 }
 
 // Alerts is a collection of Alert objects. It's mostly just used for JSON parsing purposes.
@@ -36,21 +44,30 @@ type Alerts []*Alert
 
 // JsonShapedAlerts is an intermediate representation of the JSON data returned by the API.
 type JsonShapedAlerts struct {
-	Data []struct {
-		ID         string `json:"id"`
-		Attributes struct {
-			Name          string          `json:"name"`
-			Description   string          `json:"description"`
-			Labels        []*Label        `json:"labels"`
-			AlertingRules []*AlertingRule `json:"alerting-rules"`
-			Expression    struct {
-				Operand           string `json:"operand"`
-				EnableNoDataAlert bool   `json:"enable-no-data-alert"`
-				NoDataDuration    int    `json:"no-data-duration-ms"`
-				Thresholds        struct {
-					Warning  float64 `json:"warning"`
-					Critical float64 `json:"critical"`
-				}
+	Data []JsonShapedAlertData
+}
+
+// JsonShapedAlert is an intermediate representation of the JSON data returned by the API for a single alert.
+type JsonShapedAlert struct {
+	Data JsonShapedAlertData
+}
+
+// JsonShapedAlertData is an intermediate representation of a single alert in the JSON data returned by the API.
+type JsonShapedAlertData struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Name          string          `json:"name"`
+		Description   string          `json:"description"`
+		Labels        []*Label        `json:"labels"`
+		Queries       []*AlertQuery   `json:"queries"`
+		AlertingRules []*AlertingRule `json:"alerting-rules"`
+		Expression    struct {
+			Operand           string `json:"operand"`
+			EnableNoDataAlert bool   `json:"enable-no-data-alert"`
+			NoDataDuration    int    `json:"no-data-duration-ms"`
+			Thresholds        struct {
+				Warning  *float64 `json:"warning"`
+				Critical *float64 `json:"critical"`
 			}
 		}
 	}
@@ -67,19 +84,31 @@ type JsonShapedAlertStatus struct {
 
 // Snoozification represents the current snooze status of an alert.
 type Snoozification struct {
-	snoozed bool
-	until   int64
+	snoozed   bool
+	until     int64
+	snoozeIDs []string
 }
 
 // JsonShapedSnoozifications is an intermediate representation of the JSON data returned by the API.
 type JsonShapedSnoozifications struct {
 	Data []struct {
+		ID         string `json:"id"`
 		Attributes struct {
 			Until int64 `json:"ends-micros"`
 		}
 	}
 }
 
+// JsonShapedSnoozeRequest is an intermediate representation of the JSON body sent to the API to create a snooze.
+type JsonShapedSnoozeRequest struct {
+	Data struct {
+		Attributes struct {
+			Until  int64  `json:"ends-micros"`
+			Reason string `json:"reason,omitempty"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
 // Destinations returns all destinations associated with the alert. This may involve fetching the destinations from the
 // API if they haven't been fetched yet.
 func (a *Alert) Destinations() ([]*AlertDestination, error) {
@@ -97,24 +126,40 @@ func (a *Alert) Destinations() ([]*AlertDestination, error) {
 	return destinations, nil
 }
 
-// Status returns the current status of the alert. This will likely involve a request to the API.
+// Status returns the current status of the alert. Unless it was recently pushed by a webhook, this will involve a
+// request to the API.
 func (a *Alert) Status() (string, error) {
 	if a.status == UnknownStatus {
-		response, err := restapi.GetCloudObsResource("/" + a.Project.Organization.ID + "/projects/" + a.Project.ID + "/metric_alerts/" + a.ID + "/status")
-		if err != nil {
-			return "", errors.New("Failed to fetch alert status: " + err.Error())
+		if status, ok := a.cachedStatus(); ok {
+			a.status = status
+			return a.status, nil
 		}
 
-		status := JsonShapedAlertStatus{}
-
-		err = json.NewDecoder(response.Body).Decode(&status)
+		_, err := a.FetchStatus()
 		if err != nil {
-			return "", errors.New("Failed to parse alert status: " + err.Error())
+			return "", err
 		}
+	}
+
+	return a.status, nil
+}
+
+// FetchStatus fetches the current status of the alert from the API, ignoring any status pushed by a webhook.
+func (a *Alert) FetchStatus() (string, error) {
+	response, err := restapi.GetCloudObsResource(a.path() + "/status")
+	if err != nil {
+		return "", errors.New("Failed to fetch alert status: " + err.Error())
+	}
+
+	status := JsonShapedAlertStatus{}
 
-		a.status = status.Data.Attributes.Status
+	err = json.NewDecoder(response.Body).Decode(&status)
+	if err != nil {
+		return "", errors.New("Failed to parse alert status: " + err.Error())
 	}
 
+	a.status = status.Data.Attributes.Status
+
 	return a.status, nil
 }
 
@@ -158,28 +203,34 @@ func (a *Alerts) UnmarshalJSON(rawJson []byte) error {
 	}
 
 	for _, d := range parsedJson.Data {
-		alert := &Alert{
-			ID:                   d.ID,
-			Name:                 d.Attributes.Name,
-			Description:          d.Attributes.Description,
-			Labels:               d.Attributes.Labels,
-			AlertingRules:        d.Attributes.AlertingRules,
-			EnableNoDataAlert:    d.Attributes.Expression.EnableNoDataAlert,
-			EnableNoDataDuration: d.Attributes.Expression.NoDataDuration,
-			Operand:              d.Attributes.Expression.Operand,
-			WarningThreshold:     d.Attributes.Expression.Thresholds.Warning,
-			CriticalThreshold:    d.Attributes.Expression.Thresholds.Critical,
-			status:               UnknownStatus,
-		}
+		*a = append(*a, d.toAlert())
+	}
 
-		for _, rule := range alert.AlertingRules {
-			rule.Alert = alert
-		}
+	return nil
+}
 
-		*a = append(*a, alert)
+// toAlert converts the JSON representation of an alert into an Alert. The alert's Project isn't set.
+func (d JsonShapedAlertData) toAlert() *Alert {
+	alert := &Alert{
+		ID:                   d.ID,
+		Name:                 d.Attributes.Name,
+		Description:          d.Attributes.Description,
+		Labels:               d.Attributes.Labels,
+		Queries:              d.Attributes.Queries,
+		AlertingRules:        d.Attributes.AlertingRules,
+		EnableNoDataAlert:    d.Attributes.Expression.EnableNoDataAlert,
+		EnableNoDataDuration: d.Attributes.Expression.NoDataDuration,
+		Operand:              d.Attributes.Expression.Operand,
+		WarningThreshold:     d.Attributes.Expression.Thresholds.Warning,
+		CriticalThreshold:    d.Attributes.Expression.Thresholds.Critical,
+		status:               UnknownStatus,
 	}
 
-	return nil
+	for _, rule := range alert.AlertingRules {
+		rule.Alert = alert
+	}
+
+	return alert
 }
 
 // FetchAlerts fetches all alerts for a given project from the backing API.
@@ -207,7 +258,7 @@ func FetchAlerts(p *Project) ([]*Alert, error) {
 
 // FetchSnoozification fetches the Snoozification status for the alert from the backing API.
 func (a *Alert) FetchSnoozification() (Snoozification, error) {
-	response, err := restapi.GetCloudObsResource("/" + a.Project.Organization.ID + "/projects/" + a.Project.ID + "/metric_alerts/" + a.ID + "/snoozes")
+	response, err := restapi.GetCloudObsResource(a.snoozesPath())
 	if err != nil {
 		return Snoozification{}, errors.New("Failed to fetch Snoozification: " + err.Error())
 	}
@@ -225,17 +276,84 @@ func (a *Alert) FetchSnoozification() (Snoozification, error) {
 		}, nil
 	}
 
+	snoozeIDs := make([]string, len(jsonShapedSnoozifications.Data))
+	for i, snooze := range jsonShapedSnoozifications.Data {
+		snoozeIDs[i] = snooze.ID
+	}
+
 	return Snoozification{
-		snoozed: true,
-		until:   jsonShapedSnoozifications.Data[0].Attributes.Until,
+		snoozed:   true,
+		until:     jsonShapedSnoozifications.Data[0].Attributes.Until,
+		snoozeIDs: snoozeIDs,
 	}, nil
 }
 
+// Snooze snoozes the alert until the given time, then refreshes the alert's snooze state from the backing API.
+func (a *Alert) Snooze(ctx context.Context, until time.Time, reason string) error {
+	if !until.After(time.Now()) {
+		return errors.New("Cannot snooze alert: snooze must end in the future")
+	}
+
+	var request JsonShapedSnoozeRequest
+	request.Data.Attributes.Until = until.UnixMicro()
+	request.Data.Attributes.Reason = reason
+
+	_, err := restapi.CreateCloudObsResource(ctx, a.snoozesPath(), request)
+	if err != nil {
+		return errors.New("Failed to snooze alert: " + err.Error())
+	}
+
+	return a.refreshSnoozification()
+}
+
+// Unsnooze removes every active snooze from the alert, then refreshes the alert's snooze state from the backing API.
+func (a *Alert) Unsnooze(ctx context.Context) error {
+	s, err := a.FetchSnoozification()
+	if err != nil {
+		return err
+	}
+
+	for _, snoozeID := range s.snoozeIDs {
+		_, err = restapi.DeleteCloudObsResource(ctx, a.snoozesPath()+"/"+snoozeID)
+		if err != nil {
+			return errors.New("Failed to unsnooze alert: " + err.Error())
+		}
+	}
+
+	return a.refreshSnoozification()
+}
+
+// refreshSnoozification replaces the alert's cached snooze state with the current state from the backing API.
+func (a *Alert) refreshSnoozification() error {
+	s, err := a.FetchSnoozification()
+	if err != nil {
+		return err
+	}
+
+	a.snoozification = &s
+	return nil
+}
+
+// path returns the API path of the alert.
+func (a *Alert) path() string {
+	return "/" + a.Project.Organization.ID + "/projects/" + a.Project.ID + "/metric_alerts/" + a.ID
+}
+
+// snoozesPath returns the API path of the alert's snoozes.
+func (a *Alert) snoozesPath() string {
+	return a.path() + "/snoozes"
+}
+
 // AssociatedCIs returns the set of CIs associated with this Alert. It will likely require 1 request per CI to
 // the backing ServiceNow API.
 func (a *Alert) AssociatedCIs() ([]*CI, error) {
+	ciIdentifiers, err := a.AssociatedCIIdentifiers()
+	if err != nil {
+		return nil, err
+	}
+
 	var cis []*CI
-	for _, ciIdentifier := range a.AssociatedCIIdentifiers() {
+	for _, ciIdentifier := range ciIdentifiers {
 		ci, err := FetchCI(ciIdentifier)
 		if err != nil {
 			return nil, err
@@ -247,18 +365,51 @@ func (a *Alert) AssociatedCIs() ([]*CI, error) {
 	return cis, nil
 }
 
-// AssociatedCIIdentifiers returns the set of CIIdentifiers associated with this Alert. This function should _not_
-// require any requests to an API, as it is derived from the Alert data.
-func (a *Alert) AssociatedCIIdentifiers() []*CIIdentifier {
-	var ciIdentifiers []*CIIdentifier
-	for _, label := range a.Labels {
-		if label.Key == "sn_ci" {
-			ciIdentifiers = append(ciIdentifiers, &CIIdentifier{
-				SysID:     label.Value[:strings.Index(label.Value, ":")],
-				ClassName: label.Value[strings.Index(label.Value, ":")+1:],
-			})
+// AssociatedCIIdentifiers returns the set of CIIdentifiers associated with this Alert by the configured
+// CIAssociationStrategies. Label-based strategies don't require any requests to an API, but CMDB lookups do.
+func (a *Alert) AssociatedCIIdentifiers() ([]*CIIdentifier, error) {
+	err := a.associateCIs()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.ciAssociations.ciIdentifiers, nil
+}
+
+// CIAssociationErrors returns a description of each label that looked like a CI association but couldn't be
+// resolved to a CI, such as an "sn_ci" label that isn't in "sysid:class" form.
+func (a *Alert) CIAssociationErrors() ([]*CIAssociationError, error) {
+	err := a.associateCIs()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.ciAssociations.errors, nil
+}
+
+// associateCIs applies every configured CIAssociationStrategy to the alert and caches the merged results.
+func (a *Alert) associateCIs() error {
+	if a.ciAssociations != nil {
+		return nil
+	}
+
+	associations := &ciAssociations{errors: []*CIAssociationError{}}
+	seen := make(map[string]bool)
+	for _, strategy := range CIAssociationStrategies {
+		ciIdentifiers, associationErrors, err := strategy.Associate(a)
+		if err != nil {
+			return err
 		}
+
+		for _, ciIdentifier := range ciIdentifiers {
+			if !seen[ciIdentifier.SysID] {
+				seen[ciIdentifier.SysID] = true
+				associations.ciIdentifiers = append(associations.ciIdentifiers, ciIdentifier)
+			}
+		}
+		associations.errors = append(associations.errors, associationErrors...)
 	}
 
-	return ciIdentifiers
+	a.ciAssociations = associations
+	return nil
 }
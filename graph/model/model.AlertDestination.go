@@ -28,19 +28,29 @@ type AlertDestinations []*AlertDestination
 // JsonShapedAlertDestinations is an intermediate representation of the JSON data returned by the API. It's only used for
 // parsing the JSON data.
 type JsonShapedAlertDestinations struct {
-	Data []struct {
-		ID         string
-		Attributes struct {
-			Name            string
-			DestinationType string `json:"destination_type"`
-			Url             string
-			CustomHeaders   map[string]string `json:"custom_headers"`
-			Channel         string
-			Scope           string
-			BodyTemplate    string            `json:"template"`
-			IntegrationKey  string            `json:"integration_key"`
-			ServiceNowAuth  map[string]string `json:"auth"`
-		}
+	Data []JsonShapedAlertDestinationData
+}
+
+// JsonShapedAlertDestination is an intermediate representation of the JSON data returned by the API for a single
+// destination.
+type JsonShapedAlertDestination struct {
+	Data JsonShapedAlertDestinationData
+}
+
+// JsonShapedAlertDestinationData is an intermediate representation of a single destination in the JSON data returned
+// by the API.
+type JsonShapedAlertDestinationData struct {
+	ID         string
+	Attributes struct {
+		Name            string
+		DestinationType string `json:"destination_type"`
+		Url             string
+		CustomHeaders   map[string]string `json:"custom_headers"`
+		Channel         string
+		Scope           string
+		BodyTemplate    string            `json:"template"`
+		IntegrationKey  string            `json:"integration_key"`
+		ServiceNowAuth  map[string]string `json:"auth"`
 	}
 }
 
@@ -75,30 +85,35 @@ func FetchAlertDestinations(project *Project) ([]*AlertDestination, error) {
 
 	alertDestinations := make([]*AlertDestination, len(jsonShapedAlertDestinations.Data))
 	for i, alertDestination := range jsonShapedAlertDestinations.Data {
-		var authValues []*AuthValue
-		for k, v := range alertDestination.Attributes.ServiceNowAuth {
-			authValues = append(authValues, &AuthValue{Key: k, Value: v})
-		}
-
-		var customHeaders []*CustomHeader
-		for k, v := range alertDestination.Attributes.CustomHeaders {
-			customHeaders = append(customHeaders, &CustomHeader{Key: k, Value: v})
-		}
-
-		alertDestinations[i] = &AlertDestination{
-			ID:              alertDestination.ID,
-			Name:            alertDestination.Attributes.Name,
-			DestinationType: alertDestination.Attributes.DestinationType,
-			Url:             alertDestination.Attributes.Url,
-			CustomHeaders:   customHeaders,
-			Channel:         alertDestination.Attributes.Channel,
-			Scope:           alertDestination.Attributes.Scope,
-			BodyTemplate:    alertDestination.Attributes.BodyTemplate,
-			IntegrationKey:  alertDestination.Attributes.IntegrationKey,
-			ServiceNowAuth:  authValues,
-			Project:         project,
-		}
+		alertDestinations[i] = alertDestination.toAlertDestination(project)
 	}
 
 	return alertDestinations, nil
 }
+
+// toAlertDestination converts the JSON representation of a destination into an AlertDestination.
+func (d JsonShapedAlertDestinationData) toAlertDestination(project *Project) *AlertDestination {
+	var authValues []*AuthValue
+	for k, v := range d.Attributes.ServiceNowAuth {
+		authValues = append(authValues, &AuthValue{Key: k, Value: v})
+	}
+
+	var customHeaders []*CustomHeader
+	for k, v := range d.Attributes.CustomHeaders {
+		customHeaders = append(customHeaders, &CustomHeader{Key: k, Value: v})
+	}
+
+	return &AlertDestination{
+		ID:              d.ID,
+		Name:            d.Attributes.Name,
+		DestinationType: d.Attributes.DestinationType,
+		Url:             d.Attributes.Url,
+		CustomHeaders:   customHeaders,
+		Channel:         d.Attributes.Channel,
+		Scope:           d.Attributes.Scope,
+		BodyTemplate:    d.Attributes.BodyTemplate,
+		IntegrationKey:  d.Attributes.IntegrationKey,
+		ServiceNowAuth:  authValues,
+		Project:         project,
+	}
+}
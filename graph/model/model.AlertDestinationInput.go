@@ -0,0 +1,315 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// DestinationInput is the definition of an alert destination, as accepted by the createDestination and
+// updateDestination mutations. Exactly one of its fields must be set, which determines the type of destination.
+//
+// Integration keys, ServiceNow auth values and custom header values are secrets. When updating a destination, leaving
+// them out keeps the values already set on the destination, so callers who can't read them can still update it.
+type DestinationInput struct {
+	Webhook    *WebhookDestinationInput
+	BigPanda   *BigPandaDestinationInput
+	Slack      *SlackDestinationInput
+	PagerDuty  *PagerDutyDestinationInput
+	ServiceNow *ServiceNowDestinationInput
+}
+
+// WebhookDestinationInput defines a "webhook" destination.
+type WebhookDestinationInput struct {
+	Name          string
+	URL           string
+	CustomHeaders []*CustomHeaderInput
+	BodyTemplate  *string
+}
+
+// BigPandaDestinationInput defines a "bigpanda" destination.
+type BigPandaDestinationInput struct {
+	Name string
+	URL  string
+}
+
+// SlackDestinationInput defines a "slack" destination.
+type SlackDestinationInput struct {
+	Channel string
+	Scope   *string
+}
+
+// PagerDutyDestinationInput defines a "pagerduty" destination.
+type PagerDutyDestinationInput struct {
+	Name           string
+	IntegrationKey *string
+}
+
+// ServiceNowDestinationInput defines a "servicenow" destination.
+type ServiceNowDestinationInput struct {
+	Name string
+	URL  string
+	Auth []*AuthValueInput
+}
+
+// CustomHeaderInput is a header to send with each webhook request.
+type CustomHeaderInput struct {
+	Key   string
+	Value string
+}
+
+// AuthValueInput is a credential used to authenticate to ServiceNow.
+type AuthValueInput struct {
+	Key   string
+	Value string
+}
+
+// JsonShapedAlertDestinationRequest is an intermediate representation of the JSON body sent to the API to create or
+// update a destination.
+type JsonShapedAlertDestinationRequest struct {
+	Data struct {
+		Attributes JsonShapedAlertDestinationAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+// JsonShapedAlertDestinationAttributes holds the attributes of a destination sent to the API. Which attributes are
+// set depends on the destination type.
+type JsonShapedAlertDestinationAttributes struct {
+	Name            string            `json:"name,omitempty"`
+	DestinationType string            `json:"destination_type"`
+	Url             string            `json:"url,omitempty"`
+	CustomHeaders   map[string]string `json:"custom_headers,omitempty"`
+	Channel         string            `json:"channel,omitempty"`
+	Scope           string            `json:"scope,omitempty"`
+	BodyTemplate    string            `json:"template,omitempty"`
+	IntegrationKey  string            `json:"integration_key,omitempty"`
+	ServiceNowAuth  map[string]string `json:"auth,omitempty"`
+}
+
+// DestinationType returns the type of destination the input defines, or an error if it doesn't set exactly one type.
+func (in *DestinationInput) DestinationType() (string, error) {
+	var types []string
+	if in.Webhook != nil {
+		types = append(types, "webhook")
+	}
+	if in.BigPanda != nil {
+		types = append(types, "bigpanda")
+	}
+	if in.Slack != nil {
+		types = append(types, "slack")
+	}
+	if in.PagerDuty != nil {
+		types = append(types, "pagerduty")
+	}
+	if in.ServiceNow != nil {
+		types = append(types, "servicenow")
+	}
+
+	if len(types) != 1 {
+		return "", errors.New("Invalid destination: exactly one of webhook, bigPanda, slack, pagerDuty and serviceNow must be set")
+	}
+
+	return types[0], nil
+}
+
+// request validates the input and converts it into the JSON body the backing API expects. If existing is given, the
+// input is an update to it, and any secrets the input leaves out are carried over from it.
+func (in *DestinationInput) request(existing *AlertDestination) (JsonShapedAlertDestinationRequest, error) {
+	var request JsonShapedAlertDestinationRequest
+
+	destinationType, err := in.DestinationType()
+	if err != nil {
+		return request, err
+	}
+	if existing != nil && existing.DestinationType != destinationType {
+		return request, fmt.Errorf("Invalid destination: cannot change a %s destination into a %s destination", existing.DestinationType, destinationType)
+	}
+
+	attributes := &request.Data.Attributes
+	attributes.DestinationType = destinationType
+	var problems []string
+
+	switch destinationType {
+	case "webhook":
+		attributes.Name = in.Webhook.Name
+		attributes.Url = in.Webhook.URL
+		if in.Webhook.BodyTemplate != nil {
+			attributes.BodyTemplate = *in.Webhook.BodyTemplate
+		}
+		if in.Webhook.CustomHeaders != nil {
+			attributes.CustomHeaders = make(map[string]string)
+			for _, header := range in.Webhook.CustomHeaders {
+				if strings.TrimSpace(header.Key) == "" {
+					problems = append(problems, "custom header names must not be empty")
+				}
+				attributes.CustomHeaders[header.Key] = header.Value
+			}
+		} else if existing != nil {
+			attributes.CustomHeaders = make(map[string]string)
+			for _, header := range existing.CustomHeaders {
+				attributes.CustomHeaders[header.Key] = header.Value
+			}
+		}
+		problems = append(problems, requireName(attributes.Name)...)
+		problems = append(problems, requireURL(attributes.Url)...)
+	case "bigpanda":
+		attributes.Name = in.BigPanda.Name
+		attributes.Url = in.BigPanda.URL
+		problems = append(problems, requireName(attributes.Name)...)
+		problems = append(problems, requireURL(attributes.Url)...)
+	case "slack":
+		attributes.Channel = in.Slack.Channel
+		if in.Slack.Scope != nil {
+			attributes.Scope = *in.Slack.Scope
+		}
+		if strings.TrimSpace(attributes.Channel) == "" {
+			problems = append(problems, "channel is required")
+		}
+	case "pagerduty":
+		attributes.Name = in.PagerDuty.Name
+		if in.PagerDuty.IntegrationKey != nil {
+			attributes.IntegrationKey = *in.PagerDuty.IntegrationKey
+		} else if existing != nil {
+			attributes.IntegrationKey = existing.IntegrationKey
+		}
+		problems = append(problems, requireName(attributes.Name)...)
+		if strings.TrimSpace(attributes.IntegrationKey) == "" {
+			problems = append(problems, "integrationKey is required")
+		}
+	case "servicenow":
+		attributes.Name = in.ServiceNow.Name
+		attributes.Url = in.ServiceNow.URL
+		attributes.ServiceNowAuth = make(map[string]string)
+		if in.ServiceNow.Auth != nil {
+			for _, authValue := range in.ServiceNow.Auth {
+				if strings.TrimSpace(authValue.Key) == "" {
+					problems = append(problems, "auth keys must not be empty")
+				}
+				attributes.ServiceNowAuth[authValue.Key] = authValue.Value
+			}
+		} else if existing != nil {
+			for _, authValue := range existing.ServiceNowAuth {
+				attributes.ServiceNowAuth[authValue.Key] = authValue.Value
+			}
+		}
+		problems = append(problems, requireName(attributes.Name)...)
+		problems = append(problems, requireURL(attributes.Url)...)
+		if len(attributes.ServiceNowAuth) == 0 {
+			problems = append(problems, "auth is required")
+		}
+	}
+
+	if len(problems) > 0 {
+		return request, errors.New("Invalid destination: " + strings.Join(problems, "; "))
+	}
+
+	return request, nil
+}
+
+// requireName returns a problem if the destination name is blank.
+func requireName(name string) []string {
+	if strings.TrimSpace(name) == "" {
+		return []string{"name is required"}
+	}
+
+	return nil
+}
+
+// requireURL returns a problem if the destination URL isn't an absolute http or https URL.
+func requireURL(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return []string{"url must be an absolute http or https URL"}
+	}
+
+	return nil
+}
+
+// CreateAlertDestination validates the input, creates a new destination from it in the project, and returns the new
+// destination. The project's cached destinations are discarded.
+func CreateAlertDestination(ctx context.Context, p *Project, input DestinationInput) (*AlertDestination, error) {
+	request, err := input.request(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := restapi.CreateCloudObsResource(ctx, "/"+p.Organization.ID+"/projects/"+p.ID+"/destinations", request)
+	if err != nil {
+		return nil, errors.New("Failed to create destination: " + err.Error())
+	}
+	p.alertDestinations = nil
+
+	return parseAlertDestinationResponse(p, response.Body)
+}
+
+// Update validates the input, replaces the destination's definition with it, and returns the updated destination.
+// The project's cached destinations are discarded.
+func (ad *AlertDestination) Update(ctx context.Context, input DestinationInput) (*AlertDestination, error) {
+	request, err := input.request(ad)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := restapi.UpdateCloudObsResource(ctx, ad.path(), request)
+	if err != nil {
+		return nil, errors.New("Failed to update destination: " + err.Error())
+	}
+	ad.Project.alertDestinations = nil
+
+	return parseAlertDestinationResponse(ad.Project, response.Body)
+}
+
+// Delete deletes the destination. Unless force is set, it refuses to delete a destination that any alert in the
+// project still routes to.
+func (ad *AlertDestination) Delete(ctx context.Context, force bool) error {
+	if !force {
+		alerts, err := ad.Project.Alerts()
+		if err != nil {
+			return err
+		}
+
+		var users []string
+		for _, alert := range alerts {
+			for _, rule := range alert.AlertingRules {
+				if rule.MessageDestinationClientId == ad.ID {
+					users = append(users, alert.ID)
+					break
+				}
+			}
+		}
+
+		if len(users) > 0 {
+			return fmt.Errorf("Cannot delete destination %s: it is used by the alerting rules of alerts %s (pass force: true to delete it anyway)", ad.ID, strings.Join(users, ", "))
+		}
+	}
+
+	_, err := restapi.DeleteCloudObsResource(ctx, ad.path())
+	if err != nil {
+		return errors.New("Failed to delete destination: " + err.Error())
+	}
+	ad.Project.alertDestinations = nil
+
+	return nil
+}
+
+// path returns the API path of the destination.
+func (ad *AlertDestination) path() string {
+	return "/" + ad.Project.Organization.ID + "/projects/" + ad.Project.ID + "/destinations/" + ad.ID
+}
+
+// parseAlertDestinationResponse parses a single destination returned by the backing API after a write.
+func parseAlertDestinationResponse(p *Project, body io.Reader) (*AlertDestination, error) {
+	var jsonShapedAlertDestination JsonShapedAlertDestination
+	err := json.NewDecoder(body).Decode(&jsonShapedAlertDestination)
+	if err != nil {
+		return nil, errors.New("Failed to parse destination: " + err.Error())
+	}
+
+	return jsonShapedAlertDestination.Data.toAlertDestination(p), nil
+}
@@ -0,0 +1,467 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// AlertInput is the full definition of a metric alert, as accepted by the createAlert and updateAlert mutations.
+type AlertInput struct {
+	Name          string
+	Description   *string
+	Labels        []*LabelInput
+	Queries       []*AlertQueryInput
+	Expression    *AlertExpressionInput
+	AlertingRules []*AlertingRuleInput
+}
+
+// LabelInput is a label to set on an alert.
+type LabelInput struct {
+	Key   string
+	Value string
+}
+
+// AlertQueryInput is a query for an alert to evaluate.
+type AlertQueryInput struct {
+	Name        string
+	QueryString string
+	Hidden      *bool
+}
+
+// AlertExpressionInput describes when an alert fires. NoDataDuration is in milliseconds.
+type AlertExpressionInput struct {
+	Operand           string
+	WarningThreshold  *float64
+	CriticalThreshold *float64
+	EnableNoDataAlert *bool
+	NoDataDuration    *int
+}
+
+// AlertingRuleInput routes an alert to a destination. UpdateInterval is in milliseconds.
+type AlertingRuleInput struct {
+	DestinationID  string
+	UpdateInterval *int
+
+	// id is the ID of an existing rule, when the input is derived from an existing alert.
+	id string
+}
+
+// JsonShapedAlertRequest is an intermediate representation of the JSON body sent to the API to create or update an
+// alert.
+type JsonShapedAlertRequest struct {
+	Data struct {
+		Attributes struct {
+			Name          string                         `json:"name"`
+			Description   string                         `json:"description"`
+			Labels        []*Label                       `json:"labels"`
+			Queries       []*AlertQuery                  `json:"queries"`
+			AlertingRules []*JsonShapedAlertingRuleInput `json:"alerting-rules"`
+			Expression    struct {
+				Operand           string `json:"operand"`
+				EnableNoDataAlert bool   `json:"enable-no-data-alert"`
+				NoDataDuration    int    `json:"no-data-duration-ms,omitempty"`
+				Thresholds        struct {
+					Warning  *float64 `json:"warning,omitempty"`
+					Critical *float64 `json:"critical,omitempty"`
+				} `json:"thresholds"`
+			} `json:"expression"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// JsonShapedAlertingRuleInput is an intermediate representation of an alerting rule sent to the API.
+type JsonShapedAlertingRuleInput struct {
+	ID                         string `json:"id,omitempty"`
+	UpdateInterval             int    `json:"update-interval-ms"`
+	MessageDestinationClientId string `json:"message-destination-client-id"`
+}
+
+// Validate checks the input for problems the backing API would reject, or that would produce an alert that can
+// never fire. All problems are reported together.
+func (in *AlertInput) Validate() error {
+	var problems []string
+
+	if strings.TrimSpace(in.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+
+	problems = append(problems, in.labelProblems()...)
+
+	if len(in.Queries) == 0 {
+		problems = append(problems, "at least one query is required")
+	}
+	queryNames := make(map[string]bool)
+	visibleQueries := 0
+	for _, query := range in.Queries {
+		if strings.TrimSpace(query.Name) == "" {
+			problems = append(problems, "query names must not be empty")
+		} else if queryNames[query.Name] {
+			problems = append(problems, fmt.Sprintf("query name %q is used more than once", query.Name))
+		}
+		queryNames[query.Name] = true
+
+		if strings.TrimSpace(query.QueryString) == "" {
+			problems = append(problems, fmt.Sprintf("query %q has no query string", query.Name))
+		}
+		if query.Hidden == nil || !*query.Hidden {
+			visibleQueries++
+		}
+	}
+	if len(in.Queries) > 0 && visibleQueries == 0 {
+		problems = append(problems, "at least one query must not be hidden")
+	}
+
+	if in.Expression == nil {
+		problems = append(problems, "expression is required")
+	} else {
+		problems = append(problems, in.Expression.problems()...)
+	}
+
+	problems = append(problems, in.alertingRuleProblems()...)
+
+	if len(problems) > 0 {
+		return errors.New("Invalid alert: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// validateLabelsAndRules checks only the labels and alerting rules of the input, for changes that write nothing else
+// back to the API. The rest of an existing alert is whatever the API already accepted, so it isn't checked again.
+func (in *AlertInput) validateLabelsAndRules() error {
+	problems := append(in.labelProblems(), in.alertingRuleProblems()...)
+	if len(problems) > 0 {
+		return errors.New("Invalid alert: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// labelProblems returns a description of each problem with the labels.
+func (in *AlertInput) labelProblems() []string {
+	var problems []string
+
+	// A key may appear more than once, as with several sn_ci labels linking an alert to several CIs, but the same
+	// key and value twice is a mistake.
+	labels := make(map[LabelInput]bool)
+	for _, label := range in.Labels {
+		if strings.TrimSpace(label.Key) == "" {
+			problems = append(problems, "label keys must not be empty")
+		} else if labels[*label] {
+			problems = append(problems, fmt.Sprintf("label %q is set to %q more than once", label.Key, label.Value))
+		}
+		labels[*label] = true
+	}
+
+	return problems
+}
+
+// alertingRuleProblems returns a description of each problem with the alerting rules.
+func (in *AlertInput) alertingRuleProblems() []string {
+	var problems []string
+
+	for _, rule := range in.AlertingRules {
+		if strings.TrimSpace(rule.DestinationID) == "" {
+			problems = append(problems, "alerting rules must have a destination")
+		}
+		if rule.UpdateInterval != nil && *rule.UpdateInterval < 0 {
+			problems = append(problems, "alerting rule update intervals must not be negative")
+		}
+	}
+
+	return problems
+}
+
+// problems returns a description of each problem with the expression.
+func (in *AlertExpressionInput) problems() []string {
+	var problems []string
+
+	if in.Operand != "above" && in.Operand != "below" {
+		problems = append(problems, `operand must be "above" or "below"`)
+	}
+
+	// An alert with neither threshold can still fire when its data stops arriving, so a no-data alert is enough.
+	noDataAlert := in.EnableNoDataAlert != nil && *in.EnableNoDataAlert && in.NoDataDuration != nil && *in.NoDataDuration > 0
+	if in.WarningThreshold == nil && in.CriticalThreshold == nil && !noDataAlert {
+		problems = append(problems, "at least one of warningThreshold and criticalThreshold is required, unless enableNoDataAlert is set")
+	}
+	if in.WarningThreshold != nil && in.CriticalThreshold != nil {
+		if in.Operand == "above" && *in.WarningThreshold >= *in.CriticalThreshold {
+			problems = append(problems, "warningThreshold must be below criticalThreshold when the operand is \"above\"")
+		}
+		if in.Operand == "below" && *in.WarningThreshold <= *in.CriticalThreshold {
+			problems = append(problems, "warningThreshold must be above criticalThreshold when the operand is \"below\"")
+		}
+	}
+
+	if in.EnableNoDataAlert != nil && *in.EnableNoDataAlert && (in.NoDataDuration == nil || *in.NoDataDuration <= 0) {
+		problems = append(problems, "noDataDuration must be positive when enableNoDataAlert is set")
+	}
+	if in.NoDataDuration != nil && *in.NoDataDuration < 0 {
+		problems = append(problems, "noDataDuration must not be negative")
+	}
+
+	return problems
+}
+
+// request converts the input into the JSON body the backing API expects. The input should already be valid.
+func (in *AlertInput) request() JsonShapedAlertRequest {
+	var request JsonShapedAlertRequest
+	attributes := &request.Data.Attributes
+
+	attributes.Name = in.Name
+	if in.Description != nil {
+		attributes.Description = *in.Description
+	}
+
+	attributes.Labels = []*Label{}
+	for _, label := range in.Labels {
+		attributes.Labels = append(attributes.Labels, &Label{Key: label.Key, Value: label.Value})
+	}
+
+	attributes.Queries = []*AlertQuery{}
+	for _, query := range in.Queries {
+		attributes.Queries = append(attributes.Queries, &AlertQuery{
+			Name:        query.Name,
+			QueryString: query.QueryString,
+			Hidden:      query.Hidden != nil && *query.Hidden,
+		})
+	}
+
+	attributes.AlertingRules = []*JsonShapedAlertingRuleInput{}
+	for _, rule := range in.AlertingRules {
+		jsonShapedRule := &JsonShapedAlertingRuleInput{ID: rule.id, MessageDestinationClientId: rule.DestinationID}
+		if rule.UpdateInterval != nil {
+			jsonShapedRule.UpdateInterval = *rule.UpdateInterval
+		}
+		attributes.AlertingRules = append(attributes.AlertingRules, jsonShapedRule)
+	}
+
+	if in.Expression != nil {
+		attributes.Expression.Operand = in.Expression.Operand
+		attributes.Expression.Thresholds.Warning = in.Expression.WarningThreshold
+		attributes.Expression.Thresholds.Critical = in.Expression.CriticalThreshold
+		if in.Expression.EnableNoDataAlert != nil {
+			attributes.Expression.EnableNoDataAlert = *in.Expression.EnableNoDataAlert
+		}
+		if in.Expression.NoDataDuration != nil {
+			attributes.Expression.NoDataDuration = *in.Expression.NoDataDuration
+		}
+	}
+
+	return request
+}
+
+// Input returns the alert's current definition as an AlertInput, for Modify to change. Existing alerting rules keep
+// their IDs.
+func (a *Alert) Input() AlertInput {
+	description := a.Description
+	input := AlertInput{
+		Name:        a.Name,
+		Description: &description,
+		Expression: &AlertExpressionInput{
+			Operand:           a.Operand,
+			WarningThreshold:  a.WarningThreshold,
+			CriticalThreshold: a.CriticalThreshold,
+			EnableNoDataAlert: &a.EnableNoDataAlert,
+			NoDataDuration:    &a.EnableNoDataDuration,
+		},
+	}
+
+	for _, label := range a.Labels {
+		input.Labels = append(input.Labels, &LabelInput{Key: label.Key, Value: label.Value})
+	}
+
+	for _, query := range a.Queries {
+		hidden := query.Hidden
+		input.Queries = append(input.Queries, &AlertQueryInput{Name: query.Name, QueryString: query.QueryString, Hidden: &hidden})
+	}
+
+	for _, rule := range a.AlertingRules {
+		updateInterval := rule.UpdateInterval
+		input.AlertingRules = append(input.AlertingRules, &AlertingRuleInput{
+			DestinationID:  rule.MessageDestinationClientId,
+			UpdateInterval: &updateInterval,
+			id:             rule.ID,
+		})
+	}
+
+	return input
+}
+
+// CreateAlert validates the input, creates a new alert from it in the project, and returns the new alert. The
+// project's cached alerts are discarded.
+func CreateAlert(ctx context.Context, p *Project, input AlertInput) (*Alert, error) {
+	err := input.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := restapi.CreateCloudObsResource(ctx, "/"+p.Organization.ID+"/projects/"+p.ID+"/metric_alerts", input.request())
+	if err != nil {
+		return nil, errors.New("Failed to create alert: " + err.Error())
+	}
+	p.invalidateAlerts()
+
+	return parseAlertResponse(p, response.Body)
+}
+
+// Update validates the input, replaces the alert's definition with it, and returns the updated alert. The
+// project's cached alerts are discarded.
+func (a *Alert) Update(ctx context.Context, input AlertInput) (*Alert, error) {
+	updated, err := a.update(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	a.Project.invalidateAlerts()
+
+	return updated, nil
+}
+
+// update validates the input and replaces the alert's definition with it, without touching the project's cached
+// alerts. It's safe to call concurrently for alerts in the same project.
+func (a *Alert) update(ctx context.Context, input AlertInput) (*Alert, error) {
+	err := input.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := restapi.UpdateCloudObsResource(ctx, a.path(), input.request())
+	if err != nil {
+		return nil, errors.New("Failed to update alert: " + err.Error())
+	}
+
+	return a.parseUpdateResponse(response.Body)
+}
+
+// errUnchanged is returned by a change passed to Modify when the alert already is the way the change would make it.
+var errUnchanged = errors.New("alert unchanged")
+
+// JsonShapedRawAlert is the JSON data for a single alert with its attributes left undecoded, so that attributes
+// lightgraph doesn't model can be sent back to the API as it returned them.
+type JsonShapedRawAlert struct {
+	Data struct {
+		Attributes map[string]json.RawMessage `json:"attributes"`
+	} `json:"data"`
+}
+
+// Modify changes the alert's labels and/or alerting rules, and returns the updated alert. The project's cached alerts
+// are discarded.
+//
+// The alert is read afresh from the API, and change edits an input built from what was read; the current alert is
+// passed along for reference. Change returns errUnchanged if there's nothing to do, in which case the current alert is
+// returned as it is. Only the labels and alerting rules of the input are validated and written back: every other
+// attribute is sent as the API returned it, including ones lightgraph doesn't model. If the API returned an ETag for the alert, the
+// update is conditional on it, so a change made by someone else in the meantime is never overwritten.
+func (a *Alert) Modify(ctx context.Context, change func(current *Alert, input *AlertInput) error) (*Alert, error) {
+	updated, err := a.modify(ctx, change)
+	if err != nil {
+		return nil, err
+	}
+	a.Project.invalidateAlerts()
+
+	return updated, nil
+}
+
+// modify is Modify, without touching the project's cached alerts. It's safe to call concurrently for alerts in the
+// same project.
+func (a *Alert) modify(ctx context.Context, change func(current *Alert, input *AlertInput) error) (*Alert, error) {
+	response, err := restapi.GetCloudObsResource(a.path())
+	if err != nil {
+		return nil, errors.New("Failed to fetch alert: " + err.Error())
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.New("Failed to fetch alert: " + err.Error())
+	}
+
+	current, err := parseAlertResponse(a.Project, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	var raw JsonShapedRawAlert
+	err = json.Unmarshal(body, &raw)
+	if err != nil || raw.Data.Attributes == nil {
+		return nil, errors.New("Failed to parse alert: no attributes")
+	}
+
+	input := current.Input()
+	err = change(current, &input)
+	if err == errUnchanged {
+		return current, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = input.validateLabelsAndRules()
+	if err != nil {
+		return nil, err
+	}
+
+	request := input.request()
+	raw.Data.Attributes["labels"], err = json.Marshal(request.Data.Attributes.Labels)
+	if err != nil {
+		return nil, err
+	}
+	raw.Data.Attributes["alerting-rules"], err = json.Marshal(request.Data.Attributes.AlertingRules)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err = restapi.UpdateCloudObsResourceIfMatch(ctx, a.path(), response.Header.Get("ETag"), raw)
+	if err == restapi.ErrPreconditionFailed {
+		return nil, fmt.Errorf("Failed to update alert: alert %s was changed by someone else meanwhile, try again", a.ID)
+	}
+	if err != nil {
+		return nil, errors.New("Failed to update alert: " + err.Error())
+	}
+
+	return a.parseUpdateResponse(response.Body)
+}
+
+// Delete deletes the alert. The project's cached alerts are discarded.
+func (a *Alert) Delete(ctx context.Context) error {
+	_, err := restapi.DeleteCloudObsResource(ctx, a.path())
+	if err != nil {
+		return errors.New("Failed to delete alert: " + err.Error())
+	}
+	a.Project.invalidateAlerts()
+
+	return nil
+}
+
+// parseUpdateResponse parses the alert returned by the backing API after the alert was updated. The request body
+// doesn't carry the alert's ID, so neither does the echo of it a dry run returns; the alert keeps its own ID then.
+func (a *Alert) parseUpdateResponse(body io.Reader) (*Alert, error) {
+	updated, err := parseAlertResponse(a.Project, body)
+	if err != nil {
+		return nil, err
+	}
+	if updated.ID == "" {
+		updated.ID = a.ID
+	}
+
+	return updated, nil
+}
+
+// parseAlertResponse parses a single alert returned by the backing API after a write.
+func parseAlertResponse(p *Project, body io.Reader) (*Alert, error) {
+	var jsonShapedAlert JsonShapedAlert
+	err := json.NewDecoder(body).Decode(&jsonShapedAlert)
+	if err != nil {
+		return nil, errors.New("Failed to parse alert: " + err.Error())
+	}
+
+	alert := jsonShapedAlert.Data.toAlert()
+	alert.Project = p
+
+	return alert, nil
+}
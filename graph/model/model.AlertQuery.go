@@ -0,0 +1,8 @@
+package model
+
+// AlertQuery is one of the queries an alert evaluates. Hidden queries are only used as inputs to other queries.
+type AlertQuery struct {
+	Name        string `json:"query-name"`
+	QueryString string `json:"query-string"`
+	Hidden      bool   `json:"hidden"`
+}
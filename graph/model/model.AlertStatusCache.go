@@ -0,0 +1,71 @@
+package model
+
+import (
+	"sync"
+	"time"
+)
+
+// AlertStatusCacheTTL is how long after it was pushed a status recorded with CacheAlertStatus is trusted, before it's
+// fetched again.
+const AlertStatusCacheTTL = 5 * time.Minute
+
+// alertStatusCache holds the statuses of alerts pushed by Cloud Obs webhooks, keyed by alertStatusCacheKey, so that
+// Alert.Status doesn't have to ask the API for them. Statuses lightgraph fetches itself aren't recorded here, so
+// without the webhook, it's always empty.
+var alertStatusCache = struct {
+	sync.Mutex
+	entries map[string]cachedAlertStatus
+}{entries: make(map[string]cachedAlertStatus)}
+
+// cachedAlertStatus is an entry in alertStatusCache.
+type cachedAlertStatus struct {
+	status    string
+	pushedAt  time.Time
+	expiresAt time.Time
+}
+
+// CacheAlertStatus records the status of an alert pushed by a webhook at the given time, so that Alert.Status returns
+// it without a request to the API until AlertStatusCacheTTL after then. A status pushed before the one already
+// recorded for the alert is ignored, so that of two notifications handled at once, the older can't overwrite the
+// newer. It reports whether the status was recorded.
+func CacheAlertStatus(orgID string, projectID string, alertID string, status string, pushedAt time.Time) bool {
+	alertStatusCache.Lock()
+	defer alertStatusCache.Unlock()
+
+	now := time.Now()
+	for key, entry := range alertStatusCache.entries {
+		if now.After(entry.expiresAt) {
+			delete(alertStatusCache.entries, key)
+		}
+	}
+
+	key := alertStatusCacheKey(orgID, projectID, alertID)
+	if entry, ok := alertStatusCache.entries[key]; ok && pushedAt.Before(entry.pushedAt) {
+		return false
+	}
+
+	alertStatusCache.entries[key] = cachedAlertStatus{
+		status:    status,
+		pushedAt:  pushedAt,
+		expiresAt: pushedAt.Add(AlertStatusCacheTTL),
+	}
+	return true
+}
+
+// cachedStatus returns the alert's status from alertStatusCache, if it's there and hasn't expired.
+func (a *Alert) cachedStatus() (string, bool) {
+	alertStatusCache.Lock()
+	defer alertStatusCache.Unlock()
+
+	entry, ok := alertStatusCache.entries[alertStatusCacheKey(a.Project.Organization.ID, a.Project.ID, a.ID)]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return "", false
+	}
+
+	return entry.status, true
+}
+
+// alertStatusCacheKey returns the key of an alert in alertStatusCache.
+func alertStatusCacheKey(orgID string, projectID string, alertID string) string {
+	return orgID + "/" + projectID + "/" + alertID
+}
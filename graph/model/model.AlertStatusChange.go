@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// AlertStatusChange is a transition in an alert's status, like from "ok" to "critical".
+type AlertStatusChange struct {
+	Alert          *Alert
+	PreviousStatus string
+	Status         string
+	ChangedAt      time.Time
+}
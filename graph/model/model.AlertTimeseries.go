@@ -0,0 +1,185 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+const (
+	// DefaultTimeseriesPoints is roughly how many points per series Alert.timeseries returns when no resolution is
+	// given.
+	DefaultTimeseriesPoints = 200
+
+	// MaxTimeseriesPoints is the most points per series Alert.timeseries will request.
+	MaxTimeseriesPoints = 10000
+)
+
+// AlertTimeseries is the data behind an alert over a time range, along with the alert's thresholds so the two can be
+// charted together.
+type AlertTimeseries struct {
+	Start             time.Time
+	End               time.Time
+	Resolution        int
+	Operand           string
+	WarningThreshold  *float64
+	CriticalThreshold *float64
+	Series            []*TimeseriesSeries
+}
+
+// TimeseriesSeries is a single series returned by a query, identified by its labels.
+type TimeseriesSeries struct {
+	QueryName string
+	Labels    []*Label
+	Points    []*TimeseriesPoint
+}
+
+// TimeseriesPoint is a single value in a series, along with the alert threshold it crosses, if any.
+type TimeseriesPoint struct {
+	Timestamp time.Time
+	Value     float64
+	Threshold ThresholdLevel
+}
+
+// JsonShapedTimeseriesQuery is an intermediate representation of the JSON body sent to the query_timeseries endpoint.
+type JsonShapedTimeseriesQuery struct {
+	Data struct {
+		Attributes struct {
+			Query        string `json:"query"`
+			OldestTime   string `json:"oldest-time"`
+			YoungestTime string `json:"youngest-time"`
+			OutputPeriod int    `json:"output-period"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// JsonShapedTimeseries is an intermediate representation of the JSON data returned by the API. Each point is a
+// [timestamp in microseconds, value] pair.
+type JsonShapedTimeseries struct {
+	Data struct {
+		Attributes struct {
+			Series []struct {
+				GroupLabels []*Label     `json:"group-labels"`
+				Points      [][2]float64 `json:"points"`
+			}
+		}
+	}
+}
+
+// Timeseries runs each of the alert's visible queries over the given time range and returns the resulting series.
+// The resolution is the number of seconds between points; if it isn't given, one is picked that returns about
+// DefaultTimeseriesPoints points per series. This requires one request to the API per visible query.
+func (a *Alert) Timeseries(start time.Time, end time.Time, resolution *int) (*AlertTimeseries, error) {
+	if !end.After(start) {
+		return nil, errors.New("timeseries end must be after start")
+	}
+
+	period := int(end.Sub(start).Seconds()) / DefaultTimeseriesPoints
+	if resolution != nil {
+		period = *resolution
+	}
+	if period < 1 {
+		if resolution != nil {
+			return nil, errors.New("timeseries resolution must be at least 1 second")
+		}
+		period = 1
+	}
+	if int(end.Sub(start).Seconds())/period > MaxTimeseriesPoints {
+		return nil, fmt.Errorf("timeseries would return more than %d points per series; use a coarser resolution", MaxTimeseriesPoints)
+	}
+
+	timeseries := &AlertTimeseries{
+		Start:             start,
+		End:               end,
+		Resolution:        period,
+		Operand:           a.Operand,
+		WarningThreshold:  a.WarningThreshold,
+		CriticalThreshold: a.CriticalThreshold,
+		Series:            []*TimeseriesSeries{},
+	}
+
+	for _, query := range a.Queries {
+		if query.Hidden {
+			continue
+		}
+
+		series, err := FetchTimeseries(a.Project, query, start, end, period)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range series {
+			for _, point := range s.Points {
+				point.Threshold = a.thresholdCrossed(point.Value)
+			}
+		}
+
+		timeseries.Series = append(timeseries.Series, series...)
+	}
+
+	return timeseries, nil
+}
+
+// thresholdCrossed returns the most severe threshold the value crosses, given the alert's operand.
+func (a *Alert) thresholdCrossed(value float64) ThresholdLevel {
+	crosses := func(threshold *float64) bool {
+		if threshold == nil {
+			return false
+		}
+		if a.Operand == "below" {
+			return value < *threshold
+		}
+		return value > *threshold
+	}
+
+	switch {
+	case crosses(a.CriticalThreshold):
+		return ThresholdLevelCritical
+	case crosses(a.WarningThreshold):
+		return ThresholdLevelWarning
+	default:
+		return ThresholdLevelNone
+	}
+}
+
+// FetchTimeseries runs a query over the given time range through the backing API's query_timeseries endpoint.
+func FetchTimeseries(p *Project, query *AlertQuery, start time.Time, end time.Time, period int) ([]*TimeseriesSeries, error) {
+	var body JsonShapedTimeseriesQuery
+	body.Data.Attributes.Query = query.QueryString
+	body.Data.Attributes.OldestTime = start.UTC().Format(time.RFC3339)
+	body.Data.Attributes.YoungestTime = end.UTC().Format(time.RFC3339)
+	body.Data.Attributes.OutputPeriod = period
+
+	response, err := restapi.PostCloudObsResource("/"+p.Organization.ID+"/projects/"+p.ID+"/telemetry/query_timeseries", body)
+	if err != nil {
+		return nil, errors.New("Failed to fetch timeseries: " + err.Error())
+	}
+
+	var jsonShapedTimeseries JsonShapedTimeseries
+	err = json.NewDecoder(response.Body).Decode(&jsonShapedTimeseries)
+	if err != nil {
+		return nil, errors.New("Failed to parse timeseries: " + err.Error())
+	}
+
+	series := make([]*TimeseriesSeries, len(jsonShapedTimeseries.Data.Attributes.Series))
+	for i, s := range jsonShapedTimeseries.Data.Attributes.Series {
+		points := make([]*TimeseriesPoint, len(s.Points))
+		for j, point := range s.Points {
+			points[j] = &TimeseriesPoint{
+				Timestamp: time.UnixMicro(int64(point[0])),
+				Value:     point[1],
+			}
+		}
+
+		series[i] = &TimeseriesSeries{
+			QueryName: query.Name,
+			Labels:    s.GroupLabels,
+			Points:    points,
+		}
+	}
+
+	return series, nil
+}
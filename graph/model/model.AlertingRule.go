@@ -1,5 +1,10 @@
 package model
 
+import (
+	"context"
+	"fmt"
+)
+
 type AlertingRule struct {
 	ID                         string `json:"id"`
 	UpdateInterval             int    `json:"update-interval-ms"`
@@ -8,6 +13,8 @@ type AlertingRule struct {
 	alertDestination           *AlertDestination
 }
 
+// Destination returns the destination the rule routes to. This may involve fetching the project's destinations from
+// the API if they haven't been fetched yet.
 func (ar *AlertingRule) Destination() (*AlertDestination, error) {
 	if ar.alertDestination == nil {
 		var err error
@@ -19,3 +26,81 @@ func (ar *AlertingRule) Destination() (*AlertDestination, error) {
 
 	return ar.alertDestination, nil
 }
+
+// AddAlertingRule routes the alert to another destination in the same project, and returns the updated alert.
+func (a *Alert) AddAlertingRule(ctx context.Context, destinationID string, updateInterval *int) (*Alert, error) {
+	err := a.checkDestination(destinationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Modify(ctx, func(current *Alert, input *AlertInput) error {
+		input.AlertingRules = append(input.AlertingRules, &AlertingRuleInput{
+			DestinationID:  destinationID,
+			UpdateInterval: updateInterval,
+		})
+		return nil
+	})
+}
+
+// UpdateAlertingRule changes the destination and/or update interval of one of the alert's rules, and returns the
+// updated alert. A new destination must be in the same project.
+func (a *Alert) UpdateAlertingRule(ctx context.Context, ruleID string, destinationID *string, updateInterval *int) (*Alert, error) {
+	if destinationID != nil {
+		err := a.checkDestination(*destinationID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return a.Modify(ctx, func(current *Alert, input *AlertInput) error {
+		found := false
+		for _, rule := range input.AlertingRules {
+			if rule.id != ruleID {
+				continue
+			}
+
+			found = true
+			if destinationID != nil {
+				rule.DestinationID = *destinationID
+			}
+			if updateInterval != nil {
+				rule.UpdateInterval = updateInterval
+			}
+		}
+		if !found {
+			return fmt.Errorf("alerting rule %s not found on alert %s", ruleID, a.ID)
+		}
+		return nil
+	})
+}
+
+// RemoveAlertingRule stops routing the alert through one of its rules, and returns the updated alert.
+func (a *Alert) RemoveAlertingRule(ctx context.Context, ruleID string) (*Alert, error) {
+	return a.Modify(ctx, func(current *Alert, input *AlertInput) error {
+		var remaining []*AlertingRuleInput
+		for _, rule := range input.AlertingRules {
+			if rule.id != ruleID {
+				remaining = append(remaining, rule)
+			}
+		}
+		if len(remaining) == len(input.AlertingRules) {
+			return fmt.Errorf("alerting rule %s not found on alert %s", ruleID, a.ID)
+		}
+		input.AlertingRules = remaining
+		return nil
+	})
+}
+
+// checkDestination returns an error unless the destination exists in the alert's project.
+func (a *Alert) checkDestination(destinationID string) error {
+	destination, err := a.Project.AlertDestination(destinationID)
+	if err != nil {
+		return err
+	}
+	if destination == nil {
+		return fmt.Errorf("destination %s not found in project %s", destinationID, a.Project.ID)
+	}
+
+	return nil
+}
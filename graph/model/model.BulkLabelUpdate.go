@@ -0,0 +1,239 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// AlertSelectorInput selects alerts in a project. An alert must match every criterion that's given.
+type AlertSelectorInput struct {
+	// Labels the alert must have. A value of "*" matches any value.
+	Labels       []*LabelInput
+	NameContains *string
+	AlertIDs     []string
+}
+
+// BulkLabelUpdateResult reports the outcome of a bulk label update.
+type BulkLabelUpdateResult struct {
+	DryRun  bool
+	Results []*AlertLabelUpdate
+}
+
+// AlertLabelUpdate reports the planned or applied label change for a single alert.
+type AlertLabelUpdate struct {
+	Alert        *Alert
+	Changed      bool
+	LabelsBefore []*Label
+	LabelsAfter  []*Label
+	Success      bool
+	Error        *string
+}
+
+// Matched returns the number of alerts the selector matched.
+func (r *BulkLabelUpdateResult) Matched() int {
+	return len(r.Results)
+}
+
+// Succeeded returns the number of alerts whose labels were updated, or would be updated in a dry run. Alerts that
+// didn't need changing count as successes.
+func (r *BulkLabelUpdateResult) Succeeded() int {
+	succeeded := 0
+	for _, result := range r.Results {
+		if result.Success {
+			succeeded++
+		}
+	}
+
+	return succeeded
+}
+
+// Failed returns the number of alerts whose labels couldn't be updated.
+func (r *BulkLabelUpdateResult) Failed() int {
+	return len(r.Results) - r.Succeeded()
+}
+
+// Matches reports whether the alert matches every criterion in the selector.
+func (sel *AlertSelectorInput) Matches(a *Alert) bool {
+	if len(sel.AlertIDs) > 0 {
+		found := false
+		for _, id := range sel.AlertIDs {
+			if id == a.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if sel.NameContains != nil && !strings.Contains(strings.ToLower(a.Name), strings.ToLower(*sel.NameContains)) {
+		return false
+	}
+
+	for _, want := range sel.Labels {
+		found := false
+		for _, label := range a.Labels {
+			if label != nil && label.Key == want.Key && (want.Value == "*" || label.Value == want.Value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// relabel returns the alert's labels with every label of the given keys removed and the given labels added. A label is
+// only added if the alert doesn't already have that exact key and value; other labels with the same key are kept, since
+// keys like the CI and incident labels can appear more than once.
+func relabel(labels []*Label, add []*LabelInput, remove []string) []*Label {
+	drop := make(map[string]bool)
+	for _, key := range remove {
+		drop[key] = true
+	}
+
+	relabeled := []*Label{}
+	have := make(map[Label]bool)
+	for _, label := range labels {
+		if label != nil && !drop[label.Key] {
+			relabeled = append(relabeled, label)
+			have[*label] = true
+		}
+	}
+	for _, input := range add {
+		label := Label{Key: input.Key, Value: input.Value}
+		if !have[label] {
+			relabeled = append(relabeled, &label)
+			have[label] = true
+		}
+	}
+
+	return relabeled
+}
+
+// labelInputs returns inputs that set the labels.
+func labelInputs(labels []*Label) []*LabelInput {
+	inputs := []*LabelInput{}
+	for _, label := range labels {
+		inputs = append(inputs, &LabelInput{Key: label.Key, Value: label.Value})
+	}
+
+	return inputs
+}
+
+// sameLabels reports whether two sets of labels are equal, ignoring order. Labels are compared as key and value pairs,
+// so a key that appears more than once is compared by each of its values.
+func sameLabels(a []*Label, b []*Label) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	counts := make(map[Label]int)
+	for _, label := range a {
+		counts[*label]++
+	}
+	for _, label := range b {
+		if counts[*label] == 0 {
+			return false
+		}
+		counts[*label]--
+	}
+
+	return true
+}
+
+// BulkUpdateAlertLabels removes and adds labels on every alert in the project that matches the selector. Alerts are
+// updated concurrently, at most restapi.MaxConcurrentRequests at a time, and a failure to update one alert doesn't
+// stop the others. Each alert's new labels are validated first, in dry runs too, and alerts whose new labels are
+// invalid are reported as failures without being updated. In a dry run, the planned changes are reported but nothing
+// is sent to the backing API. Under @dryRun, the updates go through a dry-run context instead, so the requests that would be sent
+// are recorded as well.
+func BulkUpdateAlertLabels(ctx context.Context, p *Project, selector AlertSelectorInput, add []*LabelInput, remove []string, dryRun bool) (*BulkLabelUpdateResult, error) {
+	if len(selector.Labels) == 0 && selector.NameContains == nil && len(selector.AlertIDs) == 0 {
+		return nil, errors.New("Invalid selector: at least one of labels, nameContains and alertIDs is required")
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		return nil, errors.New("Nothing to do: at least one of add and remove is required")
+	}
+	for _, label := range add {
+		if strings.TrimSpace(label.Key) == "" {
+			return nil, errors.New("Invalid label: label keys must not be empty")
+		}
+	}
+
+	alerts, err := p.Alerts()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BulkLabelUpdateResult{DryRun: dryRun || restapi.IsDryRun(ctx), Results: []*AlertLabelUpdate{}}
+	for _, alert := range alerts {
+		if !selector.Matches(alert) {
+			continue
+		}
+
+		labelsAfter := relabel(alert.Labels, add, remove)
+		update := &AlertLabelUpdate{
+			Alert:        alert,
+			Changed:      !sameLabels(alert.Labels, labelsAfter),
+			LabelsBefore: alert.Labels,
+			LabelsAfter:  labelsAfter,
+			Success:      true,
+		}
+
+		input := alert.Input()
+		input.Labels = labelInputs(labelsAfter)
+		err := input.validateLabelsAndRules()
+		if err != nil {
+			message := err.Error()
+			update.Success = false
+			update.Error = &message
+		}
+
+		result.Results = append(result.Results, update)
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	pending := []*AlertLabelUpdate{}
+	for _, update := range result.Results {
+		if update.Changed && update.Success {
+			pending = append(pending, update)
+		}
+	}
+
+	restapi.Concurrently(len(pending), func(i int) {
+		update := pending[i]
+		updated, err := update.Alert.modify(ctx, func(current *Alert, input *AlertInput) error {
+			update.LabelsBefore = current.Labels
+			update.LabelsAfter = relabel(current.Labels, add, remove)
+			if sameLabels(current.Labels, update.LabelsAfter) {
+				update.Changed = false
+				return errUnchanged
+			}
+			input.Labels = labelInputs(update.LabelsAfter)
+			return nil
+		})
+		if err != nil {
+			message := err.Error()
+			update.Success = false
+			update.Error = &message
+			return
+		}
+
+		update.Alert = updated
+	})
+
+	p.invalidateAlerts()
+
+	return result, nil
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -38,6 +39,25 @@ type JsonShapedCI struct {
 	}
 }
 
+// Alerts returns all alerts in the given projects that are associated with this CI through their CI labels. The
+// projects come from the context's ProjectCache, so each project's alerts are fetched and indexed by CI once per
+// request, however many CIs the request looks up.
+func (c *CI) Alerts(ctx context.Context, orgID string, projectIDs []string) ([]*Alert, error) {
+	projects := ProjectCacheFrom(ctx)
+
+	alerts := []*Alert{}
+	for _, projectID := range projectIDs {
+		projectAlerts, err := projects.AlertsForCI(orgID, projectID, c.CIIdentifier)
+		if err != nil {
+			return nil, err
+		}
+
+		alerts = append(alerts, projectAlerts...)
+	}
+
+	return alerts, nil
+}
+
 // FetchCI fetches a CI for a given className and sysID
 func FetchCI(c *CIIdentifier) (*CI, error) {
 	response, err := restapi.GetServiceNowResource(fmt.Sprintf("/api/now/cmdb/instance/%s/%s", c.ClassName, c.SysID))
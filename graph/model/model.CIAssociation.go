@@ -0,0 +1,371 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// DefaultCIClassName is the CMDB class used when a label identifies a CI by sys_id alone.
+const DefaultCIClassName = "cmdb_ci"
+
+// CIAssociationStrategies are the strategies used to find the CIs associated with an alert. Every strategy is applied
+// to every alert and the results are merged. The server replaces these at startup if $LS_CI_ASSOCIATION is set.
+var CIAssociationStrategies = []CIAssociationStrategy{
+	&LabelCIAssociation{Key: "sn_ci", Format: SysIDClassFormat},
+}
+
+// CIAssociationStrategy derives the set of CIs associated with an alert.
+type CIAssociationStrategy interface {
+	// Name is a short description of the strategy, used when reporting association errors.
+	Name() string
+
+	// Associate returns the CIs the strategy finds for the alert, along with a CIAssociationError for each label it
+	// couldn't make sense of. The error is reserved for failures that aren't the alert's fault, like the CMDB being
+	// unreachable.
+	Associate(a *Alert) ([]*CIIdentifier, []*CIAssociationError, error)
+}
+
+// CIAssociationError describes an alert label that looked like a CI association but couldn't be resolved to a CI.
+type CIAssociationError struct {
+	Strategy   string
+	LabelKey   string
+	LabelValue string
+	Message    string
+}
+
+// CILabelFormat describes how a CI is encoded in a label value.
+type CILabelFormat string
+
+const (
+	SysIDClassFormat CILabelFormat = "sysid:class"
+	ClassSysIDFormat CILabelFormat = "class:sysid"
+	SysIDFormat      CILabelFormat = "sysid"
+)
+
+// Parse extracts a CIIdentifier from a label value in this format. Since the sys_id and class end up in ServiceNow
+// queries, values that could change those queries are rejected.
+func (f CILabelFormat) Parse(value string) (*CIIdentifier, error) {
+	if err := restapi.CheckQueryValue(value); err != nil {
+		return nil, err
+	}
+	if strings.Contains(value, ",") {
+		return nil, errors.New("expected a single CI but the label value contains a comma")
+	}
+
+	if f == SysIDFormat {
+		if value == "" {
+			return nil, errors.New("expected a sys_id but the label value is empty")
+		}
+
+		return &CIIdentifier{SysID: value, ClassName: DefaultCIClassName}, nil
+	}
+
+	first, second, found := strings.Cut(value, ":")
+	if !found || first == "" || second == "" {
+		return nil, fmt.Errorf("expected a value in %q form", string(f))
+	}
+
+	if f == ClassSysIDFormat {
+		return &CIIdentifier{SysID: second, ClassName: first}, nil
+	}
+
+	return &CIIdentifier{SysID: first, ClassName: second}, nil
+}
+
+// Format encodes a CIIdentifier as a label value in this format.
+func (f CILabelFormat) Format(id *CIIdentifier) string {
+	switch f {
+	case SysIDFormat:
+		return id.SysID
+	case ClassSysIDFormat:
+		return id.ClassName + ":" + id.SysID
+	default:
+		return id.SysID + ":" + id.ClassName
+	}
+}
+
+// LabelCIAssociation associates an alert with the CIs encoded directly in the values of its labels with a given key.
+type LabelCIAssociation struct {
+	Key    string
+	Format CILabelFormat
+}
+
+// Name returns a short description of the strategy.
+func (s *LabelCIAssociation) Name() string {
+	return "label:" + s.Key + ":" + string(s.Format)
+}
+
+// Associate returns the CIs encoded in the alert's labels. Malformed label values are reported rather than skipped.
+func (s *LabelCIAssociation) Associate(a *Alert) ([]*CIIdentifier, []*CIAssociationError, error) {
+	var ciIdentifiers []*CIIdentifier
+	var associationErrors []*CIAssociationError
+
+	for _, label := range a.Labels {
+		if label == nil || label.Key != s.Key {
+			continue
+		}
+
+		ciIdentifier, err := s.Format.Parse(label.Value)
+		if err != nil {
+			associationErrors = append(associationErrors, &CIAssociationError{
+				Strategy:   s.Name(),
+				LabelKey:   label.Key,
+				LabelValue: label.Value,
+				Message:    err.Error(),
+			})
+			continue
+		}
+
+		ciIdentifiers = append(ciIdentifiers, ciIdentifier)
+	}
+
+	return ciIdentifiers, associationErrors, nil
+}
+
+// CMDBLookupTTL is how long a CMDBLookupCIAssociation reuses the CI it found for a label value.
+const CMDBLookupTTL = 10 * time.Minute
+
+// CMDBLookupCIAssociation associates an alert with CIs by looking up the values of its labels with a given key in a
+// field of the ServiceNow CMDB, like "host_name" or "name". Values that match a CI are cached for CMDBLookupTTL;
+// values that don't are looked up again every time, so a CI added to the CMDB is found straight away.
+type CMDBLookupCIAssociation struct {
+	LabelKey string
+	Field    string
+
+	mu      sync.Mutex
+	lookups map[string]*cmdbLookup
+}
+
+// cmdbLookup is a cached CMDB lookup.
+type cmdbLookup struct {
+	ciIdentifier *CIIdentifier
+	expires      time.Time
+}
+
+// JsonShapedCMDBLookup is an intermediate representation of the JSON data returned by the ServiceNow Table API.
+type JsonShapedCMDBLookup struct {
+	Result []struct {
+		SysID     string `json:"sys_id"`
+		ClassName string `json:"sys_class_name"`
+	}
+}
+
+// Name returns a short description of the strategy.
+func (s *CMDBLookupCIAssociation) Name() string {
+	return "lookup:" + s.LabelKey + ":" + s.Field
+}
+
+// Associate looks up each matching label value in the CMDB. Values that match no CI, or more than one, are reported
+// as association errors.
+func (s *CMDBLookupCIAssociation) Associate(a *Alert) ([]*CIIdentifier, []*CIAssociationError, error) {
+	var ciIdentifiers []*CIIdentifier
+	var associationErrors []*CIAssociationError
+
+	for _, label := range a.Labels {
+		if label == nil || label.Key != s.LabelKey {
+			continue
+		}
+
+		if err := restapi.CheckQueryValue(label.Value); err != nil {
+			associationErrors = append(associationErrors, &CIAssociationError{
+				Strategy:   s.Name(),
+				LabelKey:   label.Key,
+				LabelValue: label.Value,
+				Message:    err.Error(),
+			})
+			continue
+		}
+
+		ciIdentifier, err := s.lookup(label.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ciIdentifier == nil {
+			associationErrors = append(associationErrors, &CIAssociationError{
+				Strategy:   s.Name(),
+				LabelKey:   label.Key,
+				LabelValue: label.Value,
+				Message:    fmt.Sprintf("expected exactly one CI with %s %q", s.Field, label.Value),
+			})
+			continue
+		}
+
+		ciIdentifiers = append(ciIdentifiers, ciIdentifier)
+	}
+
+	return ciIdentifiers, associationErrors, nil
+}
+
+// lookup returns the single CI whose configured field matches value, or nil if there isn't exactly one. The value
+// must already have been checked with restapi.CheckQueryValue. The lock is only held to read and write the cache, so
+// lookups of different values don't wait for each other.
+func (s *CMDBLookupCIAssociation) lookup(value string) (*CIIdentifier, error) {
+	s.mu.Lock()
+	cached, ok := s.lookups[value]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.ciIdentifier, nil
+	}
+
+	response, err := restapi.QueryServiceNowTable(restapi.ServiceNowTableQuery{
+		Table:  "cmdb_ci",
+		Query:  s.Field + "=" + value,
+		Fields: []string{"sys_id", "sys_class_name"},
+		Limit:  2,
+	})
+	if err != nil {
+		return nil, errors.New("Failed to look up CI: " + err.Error())
+	}
+
+	var lookup JsonShapedCMDBLookup
+	err = json.NewDecoder(response.Body).Decode(&lookup)
+	if err != nil {
+		return nil, errors.New("Failed to parse CI lookup: " + err.Error())
+	}
+
+	if len(lookup.Result) != 1 {
+		return nil, nil
+	}
+	ciIdentifier := &CIIdentifier{SysID: lookup.Result[0].SysID, ClassName: lookup.Result[0].ClassName}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lookups == nil {
+		s.lookups = make(map[string]*cmdbLookup)
+	}
+	s.lookups[value] = &cmdbLookup{ciIdentifier: ciIdentifier, expires: time.Now().Add(CMDBLookupTTL)}
+
+	return ciIdentifier, nil
+}
+
+// ParseCIAssociationStrategies parses a comma-separated list of CI association strategies. Each entry is one of:
+//
+//	label:<key>[:<format>]  CIs encoded in <key> labels, in "sysid:class" (the default), "class:sysid" or "sysid" form
+//	hostname:<key>          CIs whose host_name matches the value of <key> labels
+//	name:<key>              CIs whose name matches the value of <key> labels
+func ParseCIAssociationStrategies(spec string) ([]CIAssociationStrategy, error) {
+	var strategies []CIAssociationStrategy
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid CI association strategy %q: expected <kind>:<label key>", entry)
+		}
+
+		switch parts[0] {
+		case "label":
+			format := SysIDClassFormat
+			if len(parts) == 3 {
+				format = CILabelFormat(parts[2])
+			}
+			if format != SysIDClassFormat && format != ClassSysIDFormat && format != SysIDFormat {
+				return nil, fmt.Errorf("invalid CI association strategy %q: unknown label format %q", entry, format)
+			}
+			strategies = append(strategies, &LabelCIAssociation{Key: parts[1], Format: format})
+		case "hostname", "name":
+			if len(parts) == 3 {
+				return nil, fmt.Errorf("invalid CI association strategy %q: unexpected %q", entry, parts[2])
+			}
+			field := "name"
+			if parts[0] == "hostname" {
+				field = "host_name"
+			}
+			strategies = append(strategies, &CMDBLookupCIAssociation{LabelKey: parts[1], Field: field})
+		default:
+			return nil, fmt.Errorf("invalid CI association strategy %q: unknown kind %q", entry, parts[0])
+		}
+	}
+
+	if len(strategies) == 0 {
+		return nil, errors.New("no CI association strategies configured")
+	}
+
+	return strategies, nil
+}
+
+// LinkCI associates the alert with a CI by adding a label for it, and returns the updated alert. The CI is checked
+// against the CMDB first, so a typo in the sys_id or class is caught now rather than when the association is used.
+// The label is written with the key and format of the first configured LabelCIAssociation; an alert can have several
+// labels with that key, one for each CI it's linked to. If the alert is already linked to the CI, it's returned as
+// it is.
+func (a *Alert) LinkCI(ctx context.Context, ciIdentifier *CIIdentifier) (*Alert, error) {
+	_, err := FetchCI(ciIdentifier)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot link CI %s (%s): %s", ciIdentifier.SysID, ciIdentifier.ClassName, err.Error())
+	}
+
+	var strategy *LabelCIAssociation
+	for _, s := range CIAssociationStrategies {
+		if labelStrategy, ok := s.(*LabelCIAssociation); ok {
+			strategy = labelStrategy
+			break
+		}
+	}
+	if strategy == nil {
+		return nil, fmt.Errorf("Cannot link CI %s: no label CI association strategy is configured", ciIdentifier.SysID)
+	}
+
+	return a.Modify(ctx, func(current *Alert, input *AlertInput) error {
+		for _, label := range current.Labels {
+			if label != nil && labelLinksCI(label, ciIdentifier.SysID) {
+				return errUnchanged
+			}
+		}
+		input.Labels = append(input.Labels, &LabelInput{Key: strategy.Key, Value: strategy.Format.Format(ciIdentifier)})
+		return nil
+	})
+}
+
+// UnlinkCI removes every label that associates the alert with the CI with the given sys_id, and returns the updated
+// alert. CIs associated by CMDB lookups can't be unlinked this way, since their labels may mean more than the link.
+func (a *Alert) UnlinkCI(ctx context.Context, sysID string) (*Alert, error) {
+	return a.Modify(ctx, func(current *Alert, input *AlertInput) error {
+		input.Labels = nil
+
+		found := false
+		for _, label := range current.Labels {
+			if label == nil {
+				continue
+			}
+			if labelLinksCI(label, sysID) {
+				found = true
+				continue
+			}
+			input.Labels = append(input.Labels, &LabelInput{Key: label.Key, Value: label.Value})
+		}
+		if !found {
+			return fmt.Errorf("Cannot unlink CI %s: no label on alert %s links it", sysID, a.ID)
+		}
+		return nil
+	})
+}
+
+// labelLinksCI reports whether any configured LabelCIAssociation reads the label as a link to the CI with the given
+// sys_id.
+func labelLinksCI(label *Label, sysID string) bool {
+	for _, s := range CIAssociationStrategies {
+		labelStrategy, ok := s.(*LabelCIAssociation)
+		if !ok || label.Key != labelStrategy.Key {
+			continue
+		}
+
+		if linked, err := labelStrategy.Format.Parse(label.Value); err == nil && linked.SysID == sysID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,200 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// MaxRelationshipDepth is the deepest CI.relationships will traverse the CMDB relationship graph. Each level costs
+// at least one request per direction to the ServiceNow API.
+const MaxRelationshipDepth = 5
+
+// MaxSysIDsPerQuery is the most sys_ids FetchCIRelationships and FetchChangeRequests put in a single query, so that a
+// wide level of the graph, or a CI with many changes, doesn't make a URL too long for ServiceNow to accept. Larger
+// sets are fetched in several requests.
+const MaxSysIDsPerQuery = 100
+
+// CIRelationship is an edge in the CMDB relationship graph, as seen from the CI whose relationships were requested.
+type CIRelationship struct {
+	ID           string
+	Type         string
+	Direction    RelationshipDirection
+	Depth        int
+	Source       *CIIdentifier
+	CIIdentifier *CIIdentifier
+	Name         string
+	ci           *CI
+}
+
+// JsonShapedCIRelationships is an intermediate representation of the JSON data returned by the ServiceNow Table API
+// for the cmdb_rel_ci table.
+type JsonShapedCIRelationships struct {
+	Result []struct {
+		SysID           string `json:"sys_id"`
+		Parent          string `json:"parent"`
+		ParentName      string `json:"parent.name"`
+		ParentClassName string `json:"parent.sys_class_name"`
+		Child           string `json:"child"`
+		ChildName       string `json:"child.name"`
+		ChildClassName  string `json:"child.sys_class_name"`
+		TypeName        string `json:"type.name"`
+	}
+}
+
+// CI returns the related CI. This requires a request to the ServiceNow API the first time it's called.
+func (r *CIRelationship) CI() (*CI, error) {
+	if r.ci == nil {
+		var err error
+		r.ci, err = FetchCI(r.CIIdentifier)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r.ci, nil
+}
+
+// Relationships walks the CMDB relationship graph outward from this CI, returning every relationship found within
+// depth hops. Downstream relationships are those where this CI is the parent ("Depends on", "Runs on"), and upstream
+// relationships are those where it's the child ("Used by", "Runs"). With both directions, each direction is walked on
+// its own, so CIs found downstream are only followed further downstream and CIs found upstream further upstream;
+// that keeps siblings, which share a dependency with this CI but don't depend on it or it on them, out of the result.
+// If typeArg is given, only relationships whose type matches it (in either direction) are returned or followed. Each
+// CI is only expanded once per direction, so cycles in the graph are safe.
+func (c *CI) Relationships(direction RelationshipDirection, typeArg *string, depth int) ([]*CIRelationship, error) {
+	if depth < 1 || depth > MaxRelationshipDepth {
+		return nil, fmt.Errorf("relationship depth must be between 1 and %d", MaxRelationshipDepth)
+	}
+
+	var directions []RelationshipDirection
+	if direction == RelationshipDirectionBoth {
+		directions = []RelationshipDirection{RelationshipDirectionDownstream, RelationshipDirectionUpstream}
+	} else {
+		directions = []RelationshipDirection{direction}
+	}
+
+	relationships := []*CIRelationship{}
+	seenRelationships := make(map[string]bool)
+	for _, dir := range directions {
+		visited := map[string]bool{c.CIIdentifier.SysID: true}
+		frontier := []*CIIdentifier{c.CIIdentifier}
+
+		for level := 1; level <= depth && len(frontier) > 0; level++ {
+			found, err := FetchCIRelationships(frontier, dir)
+			if err != nil {
+				return nil, err
+			}
+
+			var next []*CIIdentifier
+			for _, relationship := range found {
+				if seenRelationships[relationship.ID] {
+					continue
+				}
+				if typeArg != nil && !relationshipTypeMatches(relationship.Type, *typeArg) {
+					continue
+				}
+
+				seenRelationships[relationship.ID] = true
+				relationship.Depth = level
+				relationships = append(relationships, relationship)
+
+				if !visited[relationship.CIIdentifier.SysID] {
+					visited[relationship.CIIdentifier.SysID] = true
+					next = append(next, relationship.CIIdentifier)
+				}
+			}
+
+			frontier = next
+		}
+	}
+
+	return relationships, nil
+}
+
+// relationshipTypeMatches reports whether a directional relationship type like "Depends on" matches the requested
+// type, which may be either descriptor or the full "Depends on::Used by" name.
+func relationshipTypeMatches(relationshipType string, requested string) bool {
+	return strings.EqualFold(relationshipType, requested) ||
+		strings.HasPrefix(strings.ToLower(requested), strings.ToLower(relationshipType)+"::") ||
+		strings.HasSuffix(strings.ToLower(requested), "::"+strings.ToLower(relationshipType))
+}
+
+// FetchCIRelationships fetches the relationships one hop away from any of the given CIs, in one direction, from the
+// ServiceNow cmdb_rel_ci table. The CIs are queried MaxSysIDsPerQuery at a time.
+func FetchCIRelationships(ciIdentifiers []*CIIdentifier, direction RelationshipDirection) ([]*CIRelationship, error) {
+	relationships := []*CIRelationship{}
+	for start := 0; start < len(ciIdentifiers); start += MaxSysIDsPerQuery {
+		end := min(start+MaxSysIDsPerQuery, len(ciIdentifiers))
+		found, err := fetchCIRelationships(ciIdentifiers[start:end], direction)
+		if err != nil {
+			return nil, err
+		}
+		relationships = append(relationships, found...)
+	}
+
+	return relationships, nil
+}
+
+// fetchCIRelationships fetches the relationships one hop away from any of the given CIs in a single query.
+func fetchCIRelationships(ciIdentifiers []*CIIdentifier, direction RelationshipDirection) ([]*CIRelationship, error) {
+	sysIDs := make([]string, len(ciIdentifiers))
+	sources := make(map[string]*CIIdentifier, len(ciIdentifiers))
+	for i, ciIdentifier := range ciIdentifiers {
+		sysIDs[i] = ciIdentifier.SysID
+		sources[ciIdentifier.SysID] = ciIdentifier
+	}
+
+	nearField := "parent"
+	if direction == RelationshipDirectionUpstream {
+		nearField = "child"
+	}
+
+	response, err := restapi.QueryServiceNowTable(restapi.ServiceNowTableQuery{
+		Table: "cmdb_rel_ci",
+		Query: nearField + "IN" + strings.Join(sysIDs, ","),
+		Fields: []string{
+			"sys_id", "type.name",
+			"parent", "parent.name", "parent.sys_class_name",
+			"child", "child.name", "child.sys_class_name",
+		},
+	})
+	if err != nil {
+		return nil, errors.New("Failed to fetch CI relationships: " + err.Error())
+	}
+
+	var jsonShapedRelationships JsonShapedCIRelationships
+	err = json.NewDecoder(response.Body).Decode(&jsonShapedRelationships)
+	if err != nil {
+		return nil, errors.New("Failed to parse CI relationships: " + err.Error())
+	}
+
+	relationships := make([]*CIRelationship, len(jsonShapedRelationships.Result))
+	for i, r := range jsonShapedRelationships.Result {
+		// Relationship type names look like "Depends on::Used by": the parent's view, then the child's.
+		parentView, childView, found := strings.Cut(r.TypeName, "::")
+		if !found {
+			childView = parentView
+		}
+
+		relationship := &CIRelationship{ID: r.SysID, Direction: direction}
+		if direction == RelationshipDirectionUpstream {
+			relationship.Type = childView
+			relationship.Source = sources[r.Child]
+			relationship.CIIdentifier = &CIIdentifier{SysID: r.Parent, ClassName: r.ParentClassName}
+			relationship.Name = r.ParentName
+		} else {
+			relationship.Type = parentView
+			relationship.Source = sources[r.Parent]
+			relationship.CIIdentifier = &CIIdentifier{SysID: r.Child, ClassName: r.ChildClassName}
+			relationship.Name = r.ChildName
+		}
+
+		relationships[i] = relationship
+	}
+
+	return relationships, nil
+}
@@ -0,0 +1,237 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// ChangeRequest represents a ServiceNow change request.
+type ChangeRequest struct {
+	SysID            string
+	Number           string
+	ShortDescription string
+	Type             string
+	State            string
+	Risk             string
+	AssignmentGroup  string
+	StartDate        *time.Time
+	EndDate          *time.Time
+	CIIdentifier     *CIIdentifier
+}
+
+// JsonShapedChangeRequests is an intermediate representation of the JSON data returned by the ServiceNow Table API
+// for the change_request table.
+type JsonShapedChangeRequests struct {
+	Result []struct {
+		SysID            restapi.ServiceNowField `json:"sys_id"`
+		Number           restapi.ServiceNowField `json:"number"`
+		ShortDescription restapi.ServiceNowField `json:"short_description"`
+		Type             restapi.ServiceNowField `json:"type"`
+		State            restapi.ServiceNowField `json:"state"`
+		Risk             restapi.ServiceNowField `json:"risk"`
+		AssignmentGroup  restapi.ServiceNowField `json:"assignment_group"`
+		StartDate        restapi.ServiceNowField `json:"start_date"`
+		EndDate          restapi.ServiceNowField `json:"end_date"`
+		CI               restapi.ServiceNowField `json:"cmdb_ci"`
+		CIClassName      restapi.ServiceNowField `json:"cmdb_ci.sys_class_name"`
+	}
+}
+
+// JsonShapedTaskCIs is an intermediate representation of the JSON data returned by the ServiceNow Table API for the
+// task_ci table, which records the CIs affected by a task.
+type JsonShapedTaskCIs struct {
+	Result []struct {
+		Task string `json:"task"`
+	}
+}
+
+// Link returns a link to the change request in the ServiceNow UI.
+func (cr *ChangeRequest) Link() string {
+	return restapi.ServiceNowRecordURL("change_request", cr.SysID)
+}
+
+// ChangeRequests returns the ServiceNow change requests for this CI, either as their primary CI or as an affected
+// CI, whose planned window overlaps the given time range.
+func (c *CI) ChangeRequests(since *time.Time, until *time.Time) ([]*ChangeRequest, error) {
+	return FetchChangeRequests([]*CIIdentifier{c.CIIdentifier}, since, until)
+}
+
+// RecentChanges returns the change requests for any of the CIs associated with this alert whose planned window
+// overlaps the last window ms.
+func (a *Alert) RecentChanges(window int) ([]*ChangeRequest, error) {
+	if window <= 0 {
+		return nil, errors.New("window must be a positive number of milliseconds")
+	}
+
+	ciIdentifiers, err := a.AssociatedCIIdentifiers()
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now()
+	since := until.Add(-time.Duration(window) * time.Millisecond)
+	return FetchChangeRequests(ciIdentifiers, &since, &until)
+}
+
+// FetchChangeRequests fetches the change requests whose primary or affected CIs include any of the given CIs, and whose
+// planned window overlaps the given time range, latest first. This takes one request to the task_ci table to find the
+// changes affecting the CIs, then one to the change_request table for every MaxSysIDsPerQuery of those changes, or just
+// one if there are none.
+func FetchChangeRequests(ciIdentifiers []*CIIdentifier, since *time.Time, until *time.Time) ([]*ChangeRequest, error) {
+	if len(ciIdentifiers) == 0 {
+		return []*ChangeRequest{}, nil
+	}
+
+	sysIDs := make([]string, len(ciIdentifiers))
+	for i, ciIdentifier := range ciIdentifiers {
+		sysIDs[i] = ciIdentifier.SysID
+	}
+
+	taskIDs, err := fetchAffectedChangeIDs(sysIDs, changeWindow("task.", since, until))
+	if err != nil {
+		return nil, err
+	}
+
+	// The first query finds the changes whose primary CI is one of the CIs, along with the first of the affected
+	// changes. Later queries find the rest of the affected changes, which may include some the first already found.
+	window := changeWindow("", since, until)
+	query := "cmdb_ciIN" + strings.Join(sysIDs, ",") + window
+	first := min(MaxSysIDsPerQuery, len(taskIDs))
+	if first > 0 {
+		query += "^NQsys_idIN" + strings.Join(taskIDs[:first], ",") + window
+	}
+	changeRequests, err := fetchChangeRequests(query)
+	if err != nil {
+		return nil, err
+	}
+	if first == len(taskIDs) {
+		return changeRequests, nil
+	}
+
+	found := make(map[string]bool, len(changeRequests))
+	for _, changeRequest := range changeRequests {
+		found[changeRequest.SysID] = true
+	}
+	for start := first; start < len(taskIDs); start += MaxSysIDsPerQuery {
+		end := min(start+MaxSysIDsPerQuery, len(taskIDs))
+		more, err := fetchChangeRequests("sys_idIN" + strings.Join(taskIDs[start:end], ",") + window)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, changeRequest := range more {
+			if !found[changeRequest.SysID] {
+				changeRequests = append(changeRequests, changeRequest)
+				found[changeRequest.SysID] = true
+			}
+		}
+	}
+
+	// Each query's results are sorted, but not the results of all of them together. Changes without a start date go
+	// last.
+	sort.SliceStable(changeRequests, func(i, j int) bool {
+		a, b := changeRequests[i].StartDate, changeRequests[j].StartDate
+		return a != nil && (b == nil || a.After(*b))
+	})
+
+	return changeRequests, nil
+}
+
+// changeWindow returns the query conditions for a change whose planned window overlaps the given time range, with the
+// prefix before each of the change's fields. A change overlaps the range if it starts before the range ends and ends
+// after the range starts.
+func changeWindow(prefix string, since *time.Time, until *time.Time) string {
+	window := ""
+	if until != nil {
+		window += "^" + prefix + "start_date<=" + until.UTC().Format(restapi.ServiceNowDateTime)
+	}
+	if since != nil {
+		window += "^" + prefix + "end_date>=" + since.UTC().Format(restapi.ServiceNowDateTime)
+	}
+
+	return window
+}
+
+// fetchAffectedChangeIDs returns the sys_ids of the changes that list any of the CIs as affected CIs in the task_ci
+// table, and match the window conditions, each once.
+func fetchAffectedChangeIDs(sysIDs []string, window string) ([]string, error) {
+	response, err := restapi.QueryServiceNowTable(restapi.ServiceNowTableQuery{
+		Table:  "task_ci",
+		Query:  "ci_itemIN" + strings.Join(sysIDs, ",") + "^task.sys_class_name=change_request" + window,
+		Fields: []string{"task"},
+	})
+	if err != nil {
+		return nil, errors.New("Failed to fetch affected CIs: " + err.Error())
+	}
+
+	var jsonShapedTaskCIs JsonShapedTaskCIs
+	err = json.NewDecoder(response.Body).Decode(&jsonShapedTaskCIs)
+	if err != nil {
+		return nil, errors.New("Failed to parse affected CIs: " + err.Error())
+	}
+
+	taskIDs := []string{}
+	seen := make(map[string]bool)
+	for _, taskCI := range jsonShapedTaskCIs.Result {
+		if !seen[taskCI.Task] {
+			taskIDs = append(taskIDs, taskCI.Task)
+			seen[taskCI.Task] = true
+		}
+	}
+
+	return taskIDs, nil
+}
+
+// fetchChangeRequests fetches the change requests matching the query from the change_request table, latest first.
+func fetchChangeRequests(query string) ([]*ChangeRequest, error) {
+	response, err := restapi.QueryServiceNowTable(restapi.ServiceNowTableQuery{
+		Table: "change_request",
+		Query: query + "^ORDERBYDESCstart_date",
+		Fields: []string{
+			"sys_id", "number", "short_description", "type", "state", "risk", "assignment_group",
+			"start_date", "end_date", "cmdb_ci", "cmdb_ci.sys_class_name",
+		},
+		DisplayValues: true,
+	})
+	if err != nil {
+		return nil, errors.New("Failed to fetch change requests: " + err.Error())
+	}
+
+	var jsonShapedChangeRequests JsonShapedChangeRequests
+	err = json.NewDecoder(response.Body).Decode(&jsonShapedChangeRequests)
+	if err != nil {
+		return nil, errors.New("Failed to parse change requests: " + err.Error())
+	}
+
+	changeRequests := []*ChangeRequest{}
+	for _, j := range jsonShapedChangeRequests.Result {
+		changeRequest := &ChangeRequest{
+			SysID:            j.SysID.Value,
+			Number:           j.Number.Value,
+			ShortDescription: j.ShortDescription.Value,
+			Type:             j.Type.DisplayValue,
+			State:            j.State.DisplayValue,
+			Risk:             j.Risk.DisplayValue,
+			AssignmentGroup:  j.AssignmentGroup.DisplayValue,
+		}
+
+		if startDate, err := time.Parse(restapi.ServiceNowDateTime, j.StartDate.Value); err == nil {
+			changeRequest.StartDate = &startDate
+		}
+		if endDate, err := time.Parse(restapi.ServiceNowDateTime, j.EndDate.Value); err == nil {
+			changeRequest.EndDate = &endDate
+		}
+		if j.CI.Value != "" {
+			changeRequest.CIIdentifier = &CIIdentifier{SysID: j.CI.Value, ClassName: j.CIClassName.Value}
+		}
+
+		changeRequests = append(changeRequests, changeRequest)
+	}
+
+	return changeRequests, nil
+}
@@ -0,0 +1,161 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// Dashboard represents a single metric dashboard.
+type Dashboard struct {
+	ID                string
+	Name              string
+	Description       string
+	Labels            []*Label
+	Charts            []*Chart
+	TemplateVariables []*TemplateVariable
+	Project           *Project
+}
+
+// Chart is a single chart on a dashboard.
+type Chart struct {
+	ID        string        `json:"id"`
+	Title     string        `json:"title"`
+	Rank      int           `json:"rank"`
+	ChartType string        `json:"type"`
+	Queries   []*ChartQuery `json:"queries"`
+	Dashboard *Dashboard
+}
+
+// ChartQuery is one of the queries plotted on a chart.
+type ChartQuery struct {
+	Name        string `json:"query-name"`
+	QueryString string `json:"query-string"`
+	Hidden      bool   `json:"hidden"`
+	Display     string `json:"display"`
+}
+
+// TemplateVariable is a dashboard variable that can be substituted into the dashboard's chart queries.
+type TemplateVariable struct {
+	Name                   string   `json:"name"`
+	DefaultValues          []string `json:"default-values"`
+	SuggestionAttributeKey string   `json:"suggestion-attribute-key"`
+}
+
+// Dashboards is a collection of Dashboard objects. It's mostly just used for JSON parsing purposes.
+type Dashboards []*Dashboard
+
+// JsonShapedDashboards is an intermediate representation of the JSON data returned by the API.
+type JsonShapedDashboards struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Name              string              `json:"name"`
+			Description       string              `json:"description"`
+			Labels            []*Label            `json:"labels"`
+			Charts            []*Chart            `json:"charts"`
+			TemplateVariables []*TemplateVariable `json:"template-variables"`
+		}
+	}
+}
+
+// UnmarshalJSON parses the JSON data returned by the API into a collection of Dashboard structs. Note that the
+// receiver is a pointer to Dashboards, not a single Dashboard.
+func (d *Dashboards) UnmarshalJSON(rawJson []byte) error {
+	var parsedJson JsonShapedDashboards
+	jsonReader := bytes.NewReader(rawJson)
+	err := json.NewDecoder(jsonReader).Decode(&parsedJson)
+	if err != nil {
+		return err
+	}
+
+	for _, data := range parsedJson.Data {
+		dashboard := &Dashboard{
+			ID:                data.ID,
+			Name:              data.Attributes.Name,
+			Description:       data.Attributes.Description,
+			Labels:            data.Attributes.Labels,
+			Charts:            data.Attributes.Charts,
+			TemplateVariables: data.Attributes.TemplateVariables,
+		}
+
+		for _, chart := range dashboard.Charts {
+			chart.Dashboard = dashboard
+		}
+
+		*d = append(*d, dashboard)
+	}
+
+	return nil
+}
+
+// RelatedAlerts returns the alerts in the dashboard's project that evaluate any of the queries charted on the
+// dashboard. This may involve fetching the project's alerts from the API.
+func (d *Dashboard) RelatedAlerts() ([]*Alert, error) {
+	var queries []*ChartQuery
+	for _, chart := range d.Charts {
+		queries = append(queries, chart.Queries...)
+	}
+
+	return d.Project.alertsSharingQueries(queries)
+}
+
+// RelatedAlerts returns the alerts in the project that evaluate any of the queries on this chart. This may involve
+// fetching the project's alerts from the API.
+func (c *Chart) RelatedAlerts() ([]*Alert, error) {
+	return c.Dashboard.Project.alertsSharingQueries(c.Queries)
+}
+
+// alertsSharingQueries returns the project's alerts with a query whose text matches one of the given chart queries,
+// ignoring differences in whitespace.
+func (p *Project) alertsSharingQueries(queries []*ChartQuery) ([]*Alert, error) {
+	charted := make(map[string]bool)
+	for _, query := range queries {
+		charted[normalizeQuery(query.QueryString)] = true
+	}
+
+	alerts, err := p.Alerts()
+	if err != nil {
+		return nil, err
+	}
+
+	related := []*Alert{}
+	for _, alert := range alerts {
+		for _, query := range alert.Queries {
+			if charted[normalizeQuery(query.QueryString)] {
+				related = append(related, alert)
+				break
+			}
+		}
+	}
+
+	return related, nil
+}
+
+// normalizeQuery collapses runs of whitespace in a query so that queries differing only in formatting compare equal.
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// FetchDashboards fetches all metric dashboards for a given project from the backing API.
+func FetchDashboards(p *Project) ([]*Dashboard, error) {
+	response, err := restapi.GetCloudObsResource("/" + p.Organization.ID + "/projects/" + p.ID + "/metric_dashboards")
+	if err != nil {
+		return nil, errors.New("Failed to fetch dashboards: " + err.Error())
+	}
+
+	var dashboards Dashboards
+	err = json.NewDecoder(response.Body).Decode(&dashboards)
+	if err != nil {
+		return nil, errors.New("Failed to parse dashboards: " + err.Error())
+	}
+
+	for _, dashboard := range dashboards {
+		dashboard.Project = p
+	}
+
+	return dashboards, nil
+}
@@ -0,0 +1,344 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// incidentStateValues maps each IncidentState to the value ServiceNow stores in the incident table's state field.
+var incidentStateValues = map[IncidentState]string{
+	IncidentStateNew:        "1",
+	IncidentStateInProgress: "2",
+	IncidentStateOnHold:     "3",
+	IncidentStateResolved:   "6",
+	IncidentStateClosed:     "7",
+	IncidentStateCanceled:   "8",
+}
+
+// Incident represents a ServiceNow incident.
+type Incident struct {
+	SysID            string
+	Number           string
+	ShortDescription string
+	Priority         string
+	state            string
+	AssignmentGroup  string
+	OpenedAt         *time.Time
+	CIIdentifier     *CIIdentifier
+}
+
+// JsonShapedIncidents is an intermediate representation of the JSON data returned by the ServiceNow Table API for
+// the incident table.
+type JsonShapedIncidents struct {
+	Result []JsonShapedIncident
+}
+
+// JsonShapedIncident is an intermediate representation of a single incident record returned by the ServiceNow Table
+// API with display values.
+type JsonShapedIncident struct {
+	SysID            restapi.ServiceNowField `json:"sys_id"`
+	Number           restapi.ServiceNowField `json:"number"`
+	ShortDescription restapi.ServiceNowField `json:"short_description"`
+	Priority         restapi.ServiceNowField `json:"priority"`
+	State            restapi.ServiceNowField `json:"state"`
+	AssignmentGroup  restapi.ServiceNowField `json:"assignment_group"`
+	OpenedAt         restapi.ServiceNowField `json:"opened_at"`
+	CI               restapi.ServiceNowField `json:"cmdb_ci"`
+	CIClassName      restapi.ServiceNowField `json:"cmdb_ci.sys_class_name"`
+}
+
+// incidentFields are the incident table fields needed to build an Incident.
+var incidentFields = []string{
+	"sys_id", "number", "short_description", "priority", "state", "assignment_group", "opened_at",
+	"cmdb_ci", "cmdb_ci.sys_class_name",
+}
+
+// State returns the state of the incident, or nil if ServiceNow reports a state we don't know about.
+func (i *Incident) State() *IncidentState {
+	for state, value := range incidentStateValues {
+		if value == i.state {
+			return &state
+		}
+	}
+
+	return nil
+}
+
+// Link returns a link to the incident in the ServiceNow UI.
+func (i *Incident) Link() string {
+	return restapi.ServiceNowRecordURL("incident", i.SysID)
+}
+
+// Incidents returns the ServiceNow incidents recorded against this CI, most recently opened first, optionally
+// limited to those in a given state or opened since a given time.
+func (c *CI) Incidents(state *IncidentState, since *time.Time) ([]*Incident, error) {
+	return FetchIncidents([]*CIIdentifier{c.CIIdentifier}, state, since)
+}
+
+// Incidents returns the ServiceNow incidents recorded against any of the CIs associated with this alert, most
+// recently opened first.
+func (a *Alert) Incidents(state *IncidentState, since *time.Time) ([]*Incident, error) {
+	ciIdentifiers, err := a.AssociatedCIIdentifiers()
+	if err != nil {
+		return nil, err
+	}
+
+	return FetchIncidents(ciIdentifiers, state, since)
+}
+
+// toIncident converts the JSON representation of an incident into an Incident.
+func (j JsonShapedIncident) toIncident() *Incident {
+	incident := &Incident{
+		SysID:            j.SysID.Value,
+		Number:           j.Number.Value,
+		ShortDescription: j.ShortDescription.Value,
+		Priority:         j.Priority.DisplayValue,
+		state:            j.State.Value,
+		AssignmentGroup:  j.AssignmentGroup.DisplayValue,
+	}
+
+	if openedAt, err := time.Parse(restapi.ServiceNowDateTime, j.OpenedAt.Value); err == nil {
+		incident.OpenedAt = &openedAt
+	}
+	if j.CI.Value != "" {
+		incident.CIIdentifier = &CIIdentifier{SysID: j.CI.Value, ClassName: j.CIClassName.Value}
+	}
+
+	return incident
+}
+
+// FetchIncidents fetches the incidents recorded against any of the given CIs from the ServiceNow incident table.
+func FetchIncidents(ciIdentifiers []*CIIdentifier, state *IncidentState, since *time.Time) ([]*Incident, error) {
+	incidents := []*Incident{}
+	if len(ciIdentifiers) == 0 {
+		return incidents, nil
+	}
+
+	sysIDs := make([]string, len(ciIdentifiers))
+	for i, ciIdentifier := range ciIdentifiers {
+		sysIDs[i] = ciIdentifier.SysID
+	}
+
+	conditions := []string{"cmdb_ciIN" + strings.Join(sysIDs, ",")}
+	if state != nil {
+		conditions = append(conditions, "state="+incidentStateValues[*state])
+	}
+	if since != nil {
+		conditions = append(conditions, "opened_at>="+since.UTC().Format(restapi.ServiceNowDateTime))
+	}
+
+	response, err := restapi.QueryServiceNowTable(restapi.ServiceNowTableQuery{
+		Table:         "incident",
+		Query:         strings.Join(conditions, "^") + "^ORDERBYDESCopened_at",
+		Fields:        incidentFields,
+		DisplayValues: true,
+	})
+	if err != nil {
+		return nil, errors.New("Failed to fetch incidents: " + err.Error())
+	}
+
+	var jsonShapedIncidents JsonShapedIncidents
+	err = json.NewDecoder(response.Body).Decode(&jsonShapedIncidents)
+	if err != nil {
+		return nil, errors.New("Failed to parse incidents: " + err.Error())
+	}
+
+	for _, j := range jsonShapedIncidents.Result {
+		incidents = append(incidents, j.toIncident())
+	}
+
+	return incidents, nil
+}
+
+// IncidentLabelKey is the label added to an alert to link it to the ServiceNow incident opened for it.
+const IncidentLabelKey = "sn_incident"
+
+// incidentImpactUrgency maps each incident priority to the impact and urgency that produce it in ServiceNow's default
+// priority lookup rules. ServiceNow computes priority from these rather than accepting it directly.
+var incidentImpactUrgency = map[int][2]string{
+	1: {"1", "1"},
+	2: {"1", "2"},
+	3: {"2", "2"},
+	4: {"2", "3"},
+	5: {"3", "3"},
+}
+
+// AlertRefInput identifies an alert.
+type AlertRefInput struct {
+	OrgID     string
+	ProjectID string
+	AlertID   string
+}
+
+// JsonShapedIncidentRequest is an intermediate representation of the JSON body sent to the ServiceNow Table API to
+// create an incident.
+type JsonShapedIncidentRequest struct {
+	ShortDescription string `json:"short_description"`
+	Description      string `json:"description"`
+	Impact           string `json:"impact"`
+	Urgency          string `json:"urgency"`
+	AssignmentGroup  string `json:"assignment_group,omitempty"`
+	CI               string `json:"cmdb_ci,omitempty"`
+}
+
+// JsonShapedIncidentResponse is an intermediate representation of the JSON data returned by the ServiceNow Table API
+// after creating an incident.
+type JsonShapedIncidentResponse struct {
+	Result JsonShapedIncident
+}
+
+// JsonShapedAssignmentGroups is an intermediate representation of the JSON data returned by the ServiceNow Table API
+// for the sys_user_group table.
+type JsonShapedAssignmentGroups struct {
+	Result []struct {
+		SysID string `json:"sys_id"`
+	}
+}
+
+// sysIDPattern matches a ServiceNow sys_id.
+var sysIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// IncidentLabelError is returned by CreateIncidentFromAlert when it created the incident, but couldn't label the alert
+// with it. Since the incident exists, the request shouldn't just be retried, which would open another one.
+type IncidentLabelError struct {
+	Incident *Incident
+	Err      error
+}
+
+func (e *IncidentLabelError) Error() string {
+	return fmt.Sprintf("Created incident %s, but failed to label the alert with it: %s", e.Incident.Number, e.Err.Error())
+}
+
+func (e *IncidentLabelError) Unwrap() error {
+	return e.Err
+}
+
+// CreateIncidentFromAlert opens a ServiceNow incident describing the alert, with its first associated CI as the
+// incident's CI, then labels the alert with the incident number. The priority is 1 (critical) to 5 (planning), and
+// defaults to 3. The assignment group may be given as a sys_id or a group name. If labeling the alert fails, the
+// incident is returned along with an IncidentLabelError.
+func CreateIncidentFromAlert(ctx context.Context, a *Alert, priority *int, assignmentGroup *string) (*Incident, error) {
+	incidentPriority := 3
+	if priority != nil {
+		incidentPriority = *priority
+	}
+	impactUrgency, ok := incidentImpactUrgency[incidentPriority]
+	if !ok {
+		return nil, errors.New("Invalid incident: priority must be between 1 and 5")
+	}
+
+	ciIdentifiers, err := a.AssociatedCIIdentifiers()
+	if err != nil {
+		return nil, err
+	}
+	status, err := a.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	request := JsonShapedIncidentRequest{
+		ShortDescription: fmt.Sprintf("[%s] %s", strings.ToUpper(status), a.Name),
+		Description:      a.incidentDescription(status, ciIdentifiers),
+		Impact:           impactUrgency[0],
+		Urgency:          impactUrgency[1],
+	}
+	if len(ciIdentifiers) > 0 {
+		request.CI = ciIdentifiers[0].SysID
+	}
+	if assignmentGroup != nil {
+		request.AssignmentGroup, err = lookupAssignmentGroup(*assignmentGroup)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := restapi.CreateServiceNowRecord(ctx, "incident", request, incidentFields)
+	if err != nil {
+		return nil, errors.New("Failed to create incident: " + err.Error())
+	}
+
+	var jsonShapedIncident JsonShapedIncidentResponse
+	err = json.NewDecoder(response.Body).Decode(&jsonShapedIncident)
+	if err != nil {
+		return nil, errors.New("Failed to parse incident: " + err.Error())
+	}
+	incident := jsonShapedIncident.Result.toIncident()
+
+	_, err = a.Modify(ctx, func(current *Alert, input *AlertInput) error {
+		input.Labels = labelInputs(relabel(current.Labels, []*LabelInput{{Key: IncidentLabelKey, Value: incident.Number}}, nil))
+		return nil
+	})
+	if err != nil {
+		return incident, &IncidentLabelError{Incident: incident, Err: err}
+	}
+
+	return incident, nil
+}
+
+// incidentDescription describes the alert for the body of a ServiceNow incident.
+func (a *Alert) incidentDescription(status string, ciIdentifiers []*CIIdentifier) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Alert: %s\n", a.Name)
+	fmt.Fprintf(&b, "Alert ID: %s (organization %s, project %s)\n", a.ID, a.Project.Organization.ID, a.Project.ID)
+	fmt.Fprintf(&b, "Status: %s\n", status)
+	if a.Description != "" {
+		fmt.Fprintf(&b, "Description: %s\n", a.Description)
+	}
+
+	fmt.Fprintf(&b, "\nThresholds (operand %q):\n", a.Operand)
+	if a.WarningThreshold != nil {
+		fmt.Fprintf(&b, "  Warning: %g\n", *a.WarningThreshold)
+	}
+	if a.CriticalThreshold != nil {
+		fmt.Fprintf(&b, "  Critical: %g\n", *a.CriticalThreshold)
+	}
+
+	if len(ciIdentifiers) > 0 {
+		fmt.Fprintf(&b, "\nAssociated CIs:\n")
+		for _, ciIdentifier := range ciIdentifiers {
+			fmt.Fprintf(&b, "  %s (%s)\n", ciIdentifier.SysID, ciIdentifier.ClassName)
+		}
+	}
+
+	return b.String()
+}
+
+// lookupAssignmentGroup returns the sys_id of the assignment group with the given sys_id or name.
+func lookupAssignmentGroup(group string) (string, error) {
+	if sysIDPattern.MatchString(group) {
+		return group, nil
+	}
+	err := restapi.CheckQueryValue(group)
+	if err != nil {
+		return "", errors.New("Invalid assignment group: " + err.Error())
+	}
+
+	response, err := restapi.QueryServiceNowTable(restapi.ServiceNowTableQuery{
+		Table:  "sys_user_group",
+		Query:  "name=" + group,
+		Fields: []string{"sys_id"},
+		Limit:  2,
+	})
+	if err != nil {
+		return "", errors.New("Failed to look up assignment group: " + err.Error())
+	}
+
+	var groups JsonShapedAssignmentGroups
+	err = json.NewDecoder(response.Body).Decode(&groups)
+	if err != nil {
+		return "", errors.New("Failed to parse assignment group: " + err.Error())
+	}
+	if len(groups.Result) != 1 {
+		return "", fmt.Errorf("Invalid incident: expected exactly one assignment group named %q", group)
+	}
+
+	return groups.Result[0].SysID, nil
+}
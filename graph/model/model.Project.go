@@ -13,6 +13,9 @@ type Project struct {
 	Organization      *Organization
 	alerts            []*Alert
 	alertDestinations []*AlertDestination
+	alertsByCI        map[string][]*Alert
+	dashboards        []*Dashboard
+	services          []*Service
 }
 
 type JsonShapedProject struct {
@@ -53,6 +56,80 @@ func (p *Project) Alert(id string) (*Alert, error) {
 	return nil, nil
 }
 
+// invalidateAlerts discards the project's cached alerts, so they'll be fetched again the next time they're needed.
+func (p *Project) invalidateAlerts() {
+	p.alerts = nil
+	p.alertsByCI = nil
+}
+
+// Dashboards returns all metric dashboards for the project. It caches the dashboards after the first request.
+func (p *Project) Dashboards() ([]*Dashboard, error) {
+	if p.dashboards == nil {
+		var err error
+		p.dashboards, err = FetchDashboards(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p.dashboards, nil
+}
+
+// Dashboard returns the dashboard with the given ID, or nil if it doesn't exist or isn't associated with this project.
+func (p *Project) Dashboard(id string) (*Dashboard, error) {
+	dashboards, err := p.Dashboards()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dashboard := range dashboards {
+		if dashboard.ID == id {
+			return dashboard, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Services returns all services in the project's service directory. It caches the services after the first request.
+func (p *Project) Services() ([]*Service, error) {
+	if p.services == nil {
+		var err error
+		p.services, err = FetchServices(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p.services, nil
+}
+
+// AlertsForCI returns all alerts in the project that are associated with the given CI. The first call builds an index
+// of the project's alerts keyed by CI sys_id, which later calls on the same Project reuse. To share the index between
+// resolvers, get the project from a ProjectCache.
+func (p *Project) AlertsForCI(id *CIIdentifier) ([]*Alert, error) {
+	if p.alertsByCI == nil {
+		alerts, err := p.Alerts()
+		if err != nil {
+			return nil, err
+		}
+
+		p.alertsByCI = make(map[string][]*Alert)
+		for _, alert := range alerts {
+			ciIdentifiers, err := alert.AssociatedCIIdentifiers()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, ciIdentifier := range ciIdentifiers {
+				p.alertsByCI[ciIdentifier.SysID] = append(p.alertsByCI[ciIdentifier.SysID], alert)
+			}
+		}
+	}
+
+	return p.alertsByCI[id.SysID], nil
+}
+
 // AlertDestinations returns all alert destinations for the project. It caches the destinations after the first request.
 func (p *Project) AlertDestinations() ([]*AlertDestination, error) {
 	if p.alertDestinations == nil {
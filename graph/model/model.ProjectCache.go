@@ -0,0 +1,70 @@
+package model
+
+import (
+	"context"
+	"sync"
+)
+
+// ProjectCache shares projects, along with the alerts and the index of alerts by CI they cache, between the resolvers
+// of a single request, so that resolving a field for many objects, like the alerts of many CIs, fetches each
+// project's alerts only once. It's safe for concurrent use.
+type ProjectCache struct {
+	mu       sync.Mutex
+	projects map[projectKey]*cachedProject
+}
+
+type projectKey struct {
+	orgID     string
+	projectID string
+}
+
+// cachedProject is a project in a ProjectCache. The project's own caches aren't safe for concurrent use, so the
+// project is only used with its lock held.
+type cachedProject struct {
+	mu      sync.Mutex
+	project *Project
+}
+
+type projectCacheKey struct{}
+
+// WithProjectCache returns a context carrying a new, empty ProjectCache.
+func WithProjectCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, projectCacheKey{}, &ProjectCache{projects: make(map[projectKey]*cachedProject)})
+}
+
+// ProjectCacheFrom returns the context's ProjectCache. If the context has none, it returns a new, empty cache, which
+// is shared by nothing else.
+func ProjectCacheFrom(ctx context.Context) *ProjectCache {
+	cache, _ := ctx.Value(projectCacheKey{}).(*ProjectCache)
+	if cache == nil {
+		cache = &ProjectCache{projects: make(map[projectKey]*cachedProject)}
+	}
+
+	return cache
+}
+
+// AlertsForCI returns all alerts in the project that are associated with the given CI, building the project's index of
+// alerts by CI on first use.
+func (c *ProjectCache) AlertsForCI(orgID string, projectID string, id *CIIdentifier) ([]*Alert, error) {
+	cached := c.project(orgID, projectID)
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+
+	return cached.project.AlertsForCI(id)
+}
+
+// project returns the cached project, adding it to the cache if it isn't there yet.
+func (c *ProjectCache) project(orgID string, projectID string) *cachedProject {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := projectKey{orgID: orgID, projectID: projectID}
+	cached := c.projects[key]
+	if cached == nil {
+		org := &Organization{ID: orgID, Name: orgID}
+		cached = &cachedProject{project: org.Project(projectID)}
+		c.projects[key] = cached
+	}
+
+	return cached
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// Service represents a service in the project's service directory.
+type Service struct {
+	Name       string
+	Attributes []*Label
+	LastSeen   string
+	Latency    *LatencySummary
+	ErrorRate  *float64
+	Project    *Project
+}
+
+// LatencySummary summarizes a service's recent latency, in milliseconds.
+type LatencySummary struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// JsonShapedServices is an intermediate representation of the JSON data returned by the API. Latency and error rate
+// are only present if the API has recent data for the service.
+type JsonShapedServices struct {
+	Data struct {
+		Items []struct {
+			Attributes struct {
+				Name       string            `json:"name"`
+				LastSeen   string            `json:"last-seen"`
+				Attributes map[string]string `json:"attributes"`
+				Latency    *LatencySummary   `json:"latency"`
+				ErrorRate  *float64          `json:"error-rate"`
+			}
+		}
+	}
+}
+
+// Alerts returns the alerts in the service's project that mention the service, either as the value of one of their
+// labels or by name in one of their queries. This may involve fetching the project's alerts from the API.
+func (s *Service) Alerts() ([]*Alert, error) {
+	alerts, err := s.Project.Alerts()
+	if err != nil {
+		return nil, err
+	}
+
+	// Service names often contain dots and dashes, so don't count those as word boundaries.
+	mention := regexp.MustCompile(`(^|[^\w.-])` + regexp.QuoteMeta(s.Name) + `([^\w.-]|$)`)
+
+	related := []*Alert{}
+	for _, alert := range alerts {
+		if alertMentions(alert, s.Name, mention) {
+			related = append(related, alert)
+		}
+	}
+
+	return related, nil
+}
+
+// alertMentions reports whether one of the alert's labels has the given value, or one of its queries matches the
+// given pattern.
+func alertMentions(alert *Alert, value string, pattern *regexp.Regexp) bool {
+	for _, label := range alert.Labels {
+		if label != nil && label.Value == value {
+			return true
+		}
+	}
+
+	for _, query := range alert.Queries {
+		if pattern.MatchString(query.QueryString) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FetchServices fetches all services in the service directory for a given project from the backing API.
+func FetchServices(p *Project) ([]*Service, error) {
+	response, err := restapi.GetCloudObsResource("/" + p.Organization.ID + "/projects/" + p.ID + "/directory/services")
+	if err != nil {
+		return nil, errors.New("Failed to fetch services: " + err.Error())
+	}
+
+	var jsonShapedServices JsonShapedServices
+	err = json.NewDecoder(response.Body).Decode(&jsonShapedServices)
+	if err != nil {
+		return nil, errors.New("Failed to parse services: " + err.Error())
+	}
+
+	services := make([]*Service, len(jsonShapedServices.Data.Items))
+	for i, item := range jsonShapedServices.Data.Items {
+		attributes := []*Label{}
+		for k, v := range item.Attributes.Attributes {
+			attributes = append(attributes, &Label{Key: k, Value: v})
+		}
+		sort.Slice(attributes, func(i, j int) bool { return attributes[i].Key < attributes[j].Key })
+
+		services[i] = &Service{
+			Name:       item.Attributes.Name,
+			Attributes: attributes,
+			LastSeen:   item.Attributes.LastSeen,
+			Latency:    item.Attributes.Latency,
+			ErrorRate:  item.Attributes.ErrorRate,
+			Project:    p,
+		}
+	}
+
+	return services, nil
+}
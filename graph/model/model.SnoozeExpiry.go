@@ -0,0 +1,91 @@
+package model
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+// SnoozeExpiry is an alert's active snooze and when it ends.
+type SnoozeExpiry struct {
+	Alert  *Alert
+	EndsAt time.Time
+}
+
+// Remaining returns the number of milliseconds until the snooze ends, or 0 if it has ended.
+func (s *SnoozeExpiry) Remaining() int {
+	remaining := time.Until(s.EndsAt).Milliseconds()
+	if remaining < 0 {
+		return 0
+	}
+
+	return int(remaining)
+}
+
+// SnoozeEndsAt returns the time the alert's snooze ends, or nil if the alert isn't snoozed. This will likely involve
+// a request to the API.
+func (a *Alert) SnoozeEndsAt() (*time.Time, error) {
+	snoozed, err := a.Snoozed()
+	if err != nil || !snoozed {
+		return nil, err
+	}
+
+	endsAt := time.UnixMicro(a.snoozification.until).UTC()
+	return &endsAt, nil
+}
+
+// ExpiringSnoozes returns the project's active snoozes that end within the given number of milliseconds, soonest
+// first. This requires a request to the API for each of the project's alerts.
+func (p *Project) ExpiringSnoozes(within int) ([]*SnoozeExpiry, error) {
+	if within < 0 {
+		return nil, errors.New("within must not be negative")
+	}
+
+	snoozes, err := FetchActiveSnoozes(p)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(time.Duration(within) * time.Millisecond)
+	expiring := []*SnoozeExpiry{}
+	for _, snooze := range snoozes {
+		if !snooze.EndsAt.After(deadline) {
+			expiring = append(expiring, snooze)
+		}
+	}
+
+	return expiring, nil
+}
+
+// FetchActiveSnoozes fetches the snooze state of each of the project's alerts and returns the active snoozes,
+// soonest ending first. Snoozes are fetched concurrently, at most restapi.MaxConcurrentRequests at a time.
+func FetchActiveSnoozes(p *Project) ([]*SnoozeExpiry, error) {
+	alerts, err := p.Alerts()
+	if err != nil {
+		return nil, err
+	}
+
+	endsAt := make([]*time.Time, len(alerts))
+	errs := make([]error, len(alerts))
+	restapi.Concurrently(len(alerts), func(i int) {
+		endsAt[i], errs[i] = alerts[i].SnoozeEndsAt()
+	})
+
+	snoozes := []*SnoozeExpiry{}
+	for i, alert := range alerts {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if endsAt[i] != nil {
+			snoozes = append(snoozes, &SnoozeExpiry{Alert: alert, EndsAt: *endsAt[i]})
+		}
+	}
+
+	sort.Slice(snoozes, func(i, j int) bool {
+		return snoozes[i].EndsAt.Before(snoozes[j].EndsAt)
+	})
+
+	return snoozes, nil
+}
@@ -2,8 +2,152 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
 type Mutation struct {
 }
 
 type Query struct {
 }
+
+type Subscription struct {
+}
+
+type IncidentState string
+
+const (
+	IncidentStateNew        IncidentState = "NEW"
+	IncidentStateInProgress IncidentState = "IN_PROGRESS"
+	IncidentStateOnHold     IncidentState = "ON_HOLD"
+	IncidentStateResolved   IncidentState = "RESOLVED"
+	IncidentStateClosed     IncidentState = "CLOSED"
+	IncidentStateCanceled   IncidentState = "CANCELED"
+)
+
+var AllIncidentState = []IncidentState{
+	IncidentStateNew,
+	IncidentStateInProgress,
+	IncidentStateOnHold,
+	IncidentStateResolved,
+	IncidentStateClosed,
+	IncidentStateCanceled,
+}
+
+func (e IncidentState) IsValid() bool {
+	switch e {
+	case IncidentStateNew, IncidentStateInProgress, IncidentStateOnHold, IncidentStateResolved, IncidentStateClosed, IncidentStateCanceled:
+		return true
+	}
+	return false
+}
+
+func (e IncidentState) String() string {
+	return string(e)
+}
+
+func (e *IncidentState) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = IncidentState(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid IncidentState", str)
+	}
+	return nil
+}
+
+func (e IncidentState) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type RelationshipDirection string
+
+const (
+	RelationshipDirectionUpstream   RelationshipDirection = "UPSTREAM"
+	RelationshipDirectionDownstream RelationshipDirection = "DOWNSTREAM"
+	RelationshipDirectionBoth       RelationshipDirection = "BOTH"
+)
+
+var AllRelationshipDirection = []RelationshipDirection{
+	RelationshipDirectionUpstream,
+	RelationshipDirectionDownstream,
+	RelationshipDirectionBoth,
+}
+
+func (e RelationshipDirection) IsValid() bool {
+	switch e {
+	case RelationshipDirectionUpstream, RelationshipDirectionDownstream, RelationshipDirectionBoth:
+		return true
+	}
+	return false
+}
+
+func (e RelationshipDirection) String() string {
+	return string(e)
+}
+
+func (e *RelationshipDirection) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RelationshipDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RelationshipDirection", str)
+	}
+	return nil
+}
+
+func (e RelationshipDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ThresholdLevel string
+
+const (
+	ThresholdLevelNone     ThresholdLevel = "NONE"
+	ThresholdLevelWarning  ThresholdLevel = "WARNING"
+	ThresholdLevelCritical ThresholdLevel = "CRITICAL"
+)
+
+var AllThresholdLevel = []ThresholdLevel{
+	ThresholdLevelNone,
+	ThresholdLevelWarning,
+	ThresholdLevelCritical,
+}
+
+func (e ThresholdLevel) IsValid() bool {
+	switch e {
+	case ThresholdLevelNone, ThresholdLevelWarning, ThresholdLevelCritical:
+		return true
+	}
+	return false
+}
+
+func (e ThresholdLevel) String() string {
+	return string(e)
+}
+
+func (e *ThresholdLevel) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ThresholdLevel(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ThresholdLevel", str)
+	}
+	return nil
+}
+
+func (e ThresholdLevel) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -0,0 +1,32 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/djspinmonkey/lightgraph-go/graph/model"
+)
+
+// ProjectCache is a gqlgen extension that gives each operation its own model.ProjectCache, so that the resolvers of
+// an operation share the projects they look up, and the alerts those projects cache.
+type ProjectCache struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+} = ProjectCache{}
+
+// ExtensionName returns the name of the gqlgen extension.
+func (ProjectCache) ExtensionName() string {
+	return "ProjectCache"
+}
+
+// Validate checks that the extension can be used with the schema.
+func (ProjectCache) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptOperation runs the operation with a new, empty project cache.
+func (ProjectCache) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	return next(model.WithProjectCache(ctx))
+}
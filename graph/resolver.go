@@ -1,5 +1,57 @@
 package graph
 
+import (
+	"fmt"
+
+	"github.com/djspinmonkey/lightgraph-go/audit"
+	"github.com/djspinmonkey/lightgraph-go/graph/model"
+	"github.com/djspinmonkey/lightgraph-go/monitor"
+	"github.com/djspinmonkey/lightgraph-go/policy"
+)
+
 //go:generate go run github.com/99designs/gqlgen generate
 
-type Resolver struct{}
+type Resolver struct {
+	// AuditLog records mutations and answers Query.auditLog.
+	AuditLog *audit.Log
+
+	// Monitor polls alert statuses for Subscription.alertStatusChanged.
+	Monitor *monitor.Monitor
+
+	// Snoozes tracks snoozes for Subscription.snoozeExpiring and Subscription.snoozeExpired.
+	Snoozes *monitor.SnoozeTracker
+
+	// Policy limits which organizations and projects callers can see. If it's nil, they can see them all.
+	Policy *policy.Policy
+}
+
+// findAlert looks up an alert by its organization, project and alert IDs, returning an error if it doesn't exist.
+func findAlert(orgID string, projectID string, alertID string) (*model.Alert, error) {
+	org := &model.Organization{ID: orgID, Name: orgID}
+
+	alert, err := org.Project(projectID).Alert(alertID)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, fmt.Errorf("alert %s not found in project %s", alertID, projectID)
+	}
+
+	return alert, nil
+}
+
+// findAlertDestination looks up an alert destination by its organization, project and destination IDs, returning an
+// error if it doesn't exist.
+func findAlertDestination(orgID string, projectID string, destinationID string) (*model.AlertDestination, error) {
+	org := &model.Organization{ID: orgID, Name: orgID}
+
+	destination, err := org.Project(projectID).AlertDestination(destinationID)
+	if err != nil {
+		return nil, err
+	}
+	if destination == nil {
+		return nil, fmt.Errorf("destination %s not found in project %s", destinationID, projectID)
+	}
+
+	return destination, nil
+}
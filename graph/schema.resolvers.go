@@ -6,17 +6,222 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/djspinmonkey/lightgraph-go/audit"
+	"github.com/djspinmonkey/lightgraph-go/auth"
 	"github.com/djspinmonkey/lightgraph-go/graph/model"
+	"github.com/djspinmonkey/lightgraph-go/policy"
 )
 
+// Alerts is the resolver for the alerts field.
+func (r *cIResolver) Alerts(ctx context.Context, obj *model.CI, orgID string, projectIDs []string) ([]*model.Alert, error) {
+	identity := auth.IdentityFrom(ctx)
+	for _, projectID := range projectIDs {
+		if !r.Policy.Allows(identity, policy.Read, orgID, projectID) {
+			return nil, policy.Forbidden("%s may not read project %s in organization %s", identity.Subject, projectID, orgID)
+		}
+	}
+
+	return obj.Alerts(ctx, orgID, projectIDs)
+}
+
+// Relationships is the resolver for the relationships field.
+func (r *cIResolver) Relationships(ctx context.Context, obj *model.CI, direction model.RelationshipDirection, typeArg *string, depth int) ([]*model.CIRelationship, error) {
+	return obj.Relationships(direction, typeArg, depth)
+}
+
 // DoSomething is a sample resolver for a mutation.
 func (r *mutationResolver) DoSomething(ctx context.Context, task string) (string, error) {
 	// Totally do the thing.
 	return fmt.Sprintf("It is technically possible that I may have done this thing: %s", task), nil
 }
 
+// SnoozeAlert is the resolver for the snoozeAlert field.
+func (r *mutationResolver) SnoozeAlert(ctx context.Context, orgID string, projectID string, alertID string, until time.Time, reason *string) (*model.Alert, error) {
+	alert, err := findAlert(orgID, projectID, alertID)
+	if err != nil {
+		return nil, err
+	}
+
+	var snoozeReason string
+	if reason != nil {
+		snoozeReason = *reason
+	}
+
+	err = alert.Snooze(ctx, until, snoozeReason)
+	if err != nil {
+		return nil, err
+	}
+
+	return alert, nil
+}
+
+// UnsnoozeAlert is the resolver for the unsnoozeAlert field.
+func (r *mutationResolver) UnsnoozeAlert(ctx context.Context, orgID string, projectID string, alertID string) (*model.Alert, error) {
+	alert, err := findAlert(orgID, projectID, alertID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = alert.Unsnooze(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return alert, nil
+}
+
+// CreateAlert is the resolver for the createAlert field.
+func (r *mutationResolver) CreateAlert(ctx context.Context, orgID string, projectID string, input model.AlertInput) (*model.Alert, error) {
+	org := &model.Organization{ID: orgID, Name: orgID}
+	return model.CreateAlert(ctx, org.Project(projectID), input)
+}
+
+// UpdateAlert is the resolver for the updateAlert field.
+func (r *mutationResolver) UpdateAlert(ctx context.Context, orgID string, projectID string, alertID string, input model.AlertInput) (*model.Alert, error) {
+	alert, err := findAlert(orgID, projectID, alertID)
+	if err != nil {
+		return nil, err
+	}
+
+	return alert.Update(ctx, input)
+}
+
+// DeleteAlert is the resolver for the deleteAlert field.
+func (r *mutationResolver) DeleteAlert(ctx context.Context, orgID string, projectID string, alertID string) (string, error) {
+	alert, err := findAlert(orgID, projectID, alertID)
+	if err != nil {
+		return "", err
+	}
+
+	err = alert.Delete(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return alert.ID, nil
+}
+
+// CreateDestination is the resolver for the createDestination field.
+func (r *mutationResolver) CreateDestination(ctx context.Context, orgID string, projectID string, input model.DestinationInput) (*model.AlertDestination, error) {
+	org := &model.Organization{ID: orgID, Name: orgID}
+	return model.CreateAlertDestination(ctx, org.Project(projectID), input)
+}
+
+// UpdateDestination is the resolver for the updateDestination field.
+func (r *mutationResolver) UpdateDestination(ctx context.Context, orgID string, projectID string, destinationID string, input model.DestinationInput) (*model.AlertDestination, error) {
+	destination, err := findAlertDestination(orgID, projectID, destinationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return destination.Update(ctx, input)
+}
+
+// DeleteDestination is the resolver for the deleteDestination field.
+func (r *mutationResolver) DeleteDestination(ctx context.Context, orgID string, projectID string, destinationID string, force bool) (string, error) {
+	destination, err := findAlertDestination(orgID, projectID, destinationID)
+	if err != nil {
+		return "", err
+	}
+
+	err = destination.Delete(ctx, force)
+	if err != nil {
+		return "", err
+	}
+
+	return destination.ID, nil
+}
+
+// AddAlertingRule is the resolver for the addAlertingRule field.
+func (r *mutationResolver) AddAlertingRule(ctx context.Context, orgID string, projectID string, alertID string, destinationID string, updateInterval *int) (*model.Alert, error) {
+	alert, err := findAlert(orgID, projectID, alertID)
+	if err != nil {
+		return nil, err
+	}
+
+	return alert.AddAlertingRule(ctx, destinationID, updateInterval)
+}
+
+// UpdateAlertingRule is the resolver for the updateAlertingRule field.
+func (r *mutationResolver) UpdateAlertingRule(ctx context.Context, orgID string, projectID string, alertID string, ruleID string, destinationID *string, updateInterval *int) (*model.Alert, error) {
+	alert, err := findAlert(orgID, projectID, alertID)
+	if err != nil {
+		return nil, err
+	}
+
+	return alert.UpdateAlertingRule(ctx, ruleID, destinationID, updateInterval)
+}
+
+// RemoveAlertingRule is the resolver for the removeAlertingRule field.
+func (r *mutationResolver) RemoveAlertingRule(ctx context.Context, orgID string, projectID string, alertID string, ruleID string) (*model.Alert, error) {
+	alert, err := findAlert(orgID, projectID, alertID)
+	if err != nil {
+		return nil, err
+	}
+
+	return alert.RemoveAlertingRule(ctx, ruleID)
+}
+
+// CreateIncidentFromAlert is the resolver for the createIncidentFromAlert field.
+func (r *mutationResolver) CreateIncidentFromAlert(ctx context.Context, alertRef model.AlertRefInput, priority *int, assignmentGroup *string) (*model.Incident, error) {
+	alert, err := findAlert(alertRef.OrgID, alertRef.ProjectID, alertRef.AlertID)
+	if err != nil {
+		return nil, err
+	}
+
+	incident, err := model.CreateIncidentFromAlert(ctx, alert, priority, assignmentGroup)
+	var labelErr *model.IncidentLabelError
+	if errors.As(err, &labelErr) {
+		// The incident was created, so return it along with the error, rather than leave the caller to retry and
+		// open another one.
+		graphql.AddError(ctx, err)
+		return incident, nil
+	}
+
+	return incident, err
+}
+
+// BulkUpdateAlertLabels is the resolver for the bulkUpdateAlertLabels field.
+func (r *mutationResolver) BulkUpdateAlertLabels(ctx context.Context, orgID string, projectID string, selector model.AlertSelectorInput, add []*model.LabelInput, remove []string, dryRun bool) (*model.BulkLabelUpdateResult, error) {
+	org := &model.Organization{ID: orgID, Name: orgID}
+	return model.BulkUpdateAlertLabels(ctx, org.Project(projectID), selector, add, remove, dryRun)
+}
+
+// LinkCIToAlert is the resolver for the linkCIToAlert field.
+func (r *mutationResolver) LinkCIToAlert(ctx context.Context, orgID string, projectID string, alertID string, sysID string, className string) (*model.Alert, error) {
+	alert, err := findAlert(orgID, projectID, alertID)
+	if err != nil {
+		return nil, err
+	}
+
+	return alert.LinkCI(ctx, &model.CIIdentifier{SysID: sysID, ClassName: className})
+}
+
+// UnlinkCIFromAlert is the resolver for the unlinkCIFromAlert field.
+func (r *mutationResolver) UnlinkCIFromAlert(ctx context.Context, orgID string, projectID string, alertID string, sysID string) (*model.Alert, error) {
+	alert, err := findAlert(orgID, projectID, alertID)
+	if err != nil {
+		return nil, err
+	}
+
+	return alert.UnlinkCI(ctx, sysID)
+}
+
+// Project is the resolver for the project field.
+func (r *organizationResolver) Project(ctx context.Context, obj *model.Organization, id string) (*model.Project, error) {
+	identity := auth.IdentityFrom(ctx)
+	if !r.Resolver.Policy.Allows(identity, policy.Read, obj.ID, id) {
+		return nil, policy.Forbidden("%s may not read project %s in organization %s", identity.Subject, id, obj.ID)
+	}
+
+	return obj.Project(id), nil
+}
+
 // Actor is the resolver for the actor field.
 func (r *queryResolver) Actor(ctx context.Context) (*model.Actor, error) {
 	return &model.Actor{}, nil
@@ -24,6 +229,11 @@ func (r *queryResolver) Actor(ctx context.Context) (*model.Actor, error) {
 
 // Organization is the resolver for the organization field.
 func (r *queryResolver) Organization(ctx context.Context, id string) (*model.Organization, error) {
+	identity := auth.IdentityFrom(ctx)
+	if !r.Resolver.Policy.AllowsOrganization(identity, id) {
+		return nil, policy.Forbidden("%s may not read organization %s", identity.Subject, id)
+	}
+
 	return &model.Organization{ID: id, Name: id}, nil
 }
 
@@ -33,11 +243,59 @@ func (r *queryResolver) Ci(ctx context.Context, sysID string, className string)
 	return model.FetchCI(id)
 }
 
+// AuditLog is the resolver for the auditLog field.
+func (r *queryResolver) AuditLog(ctx context.Context, filter *audit.Filter) ([]*audit.Record, error) {
+	if filter == nil {
+		filter = &audit.Filter{}
+	}
+
+	identity := auth.IdentityFrom(ctx)
+	return r.Resolver.AuditLog.Query(*filter, func(record *audit.Record) bool {
+		return r.Resolver.Policy.AllowsArguments(identity, policy.Read, record.Arguments)
+	})
+}
+
+// AlertStatusChanged is the resolver for the alertStatusChanged field.
+func (r *subscriptionResolver) AlertStatusChanged(ctx context.Context, orgID string, projectID string, filter *model.AlertSelectorInput) (<-chan *model.AlertStatusChange, error) {
+	var matches func(*model.Alert) bool
+	if filter != nil {
+		matches = filter.Matches
+	}
+
+	return r.Monitor.Subscribe(ctx, orgID, projectID, matches), nil
+}
+
+// SnoozeExpiring is the resolver for the snoozeExpiring field.
+func (r *subscriptionResolver) SnoozeExpiring(ctx context.Context, orgID string, projectID string, within int) (<-chan *model.SnoozeExpiry, error) {
+	if within < 0 {
+		return nil, errors.New("within must not be negative")
+	}
+
+	return r.Snoozes.SubscribeExpiring(ctx, orgID, projectID, time.Duration(within)*time.Millisecond), nil
+}
+
+// SnoozeExpired is the resolver for the snoozeExpired field.
+func (r *subscriptionResolver) SnoozeExpired(ctx context.Context, orgID string, projectID string) (<-chan *model.SnoozeExpiry, error) {
+	return r.Snoozes.SubscribeExpired(ctx, orgID, projectID), nil
+}
+
+// CI returns CIResolver implementation.
+func (r *Resolver) CI() CIResolver { return &cIResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// Organization returns OrganizationResolver implementation.
+func (r *Resolver) Organization() OrganizationResolver { return &organizationResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+type cIResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type organizationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
@@ -0,0 +1,238 @@
+// Package idempotency lets clients safely retry mutations. A mutation sent with an Idempotency-Key header is run once;
+// repeating it with the same key within the store's window returns the original response instead of running it again.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/djspinmonkey/lightgraph-go/auth"
+)
+
+const (
+	// Header is the HTTP request header that carries the idempotency key.
+	Header = "Idempotency-Key"
+
+	// DefaultWindow is how long responses are remembered when no window is configured.
+	DefaultWindow = 24 * time.Hour
+
+	// MaxKeyLength is the longest idempotency key accepted.
+	MaxKeyLength = 255
+
+	// ReplayedExtension is the response extension set on a response that is being replayed for a repeated request.
+	ReplayedExtension = "idempotentReplay"
+)
+
+// Store remembers the responses to mutations sent with an idempotency key. It's both the HTTP middleware that picks
+// up the key and the gqlgen extension that replays responses, and should be installed as both.
+//
+// Keys are scoped to the caller: two callers using the same key don't see each other's responses. Anonymous callers,
+// when the server doesn't require authentication, all share one scope.
+//
+// Every response is remembered, including ones with errors, because a mutation that failed partway may still have
+// written something upstream. To retry a failed mutation from scratch, use a new key. Only mutations are affected;
+// queries and subscriptions ignore the key.
+type Store struct {
+	Window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// entry is a remembered response, or a placeholder for one that's still being produced.
+type entry struct {
+	fingerprint string
+	expires     time.Time
+	done        chan struct{}
+	response    *graphql.Response
+}
+
+type keyContextKey struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = &Store{}
+
+// NewStore returns a store that remembers responses for the given window.
+func NewStore(window time.Duration) *Store {
+	return &Store{Window: window, entries: make(map[string]*entry)}
+}
+
+// WithKey returns a context carrying the given idempotency key.
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// KeyFrom returns the idempotency key carried by the context, or "" if there isn't one.
+func KeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(keyContextKey{}).(string)
+	return key
+}
+
+// Middleware adds the request's idempotency key, if it has one, to the request context.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if len(key) > MaxKeyLength {
+			http.Error(w, Header+" must be at most 255 characters", http.StatusBadRequest)
+			return
+		}
+		if key != "" {
+			r = r.WithContext(WithKey(r.Context(), key))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ExtensionName returns the name of the gqlgen extension.
+func (s *Store) ExtensionName() string {
+	return "Idempotency"
+}
+
+// Validate checks that the extension can be used with the schema.
+func (s *Store) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse runs a mutation that has an idempotency key once, and replays its response for repeats by the same
+// caller. A repeat that arrives while the original is still running waits for it. Reusing a key for a different
+// request is an error.
+func (s *Store) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	key := KeyFrom(ctx)
+	oc := graphql.GetOperationContext(ctx)
+	if key == "" || oc.Operation == nil || oc.Operation.Operation != ast.Mutation {
+		return next(ctx)
+	}
+
+	subject := auth.IdentityFrom(ctx).Subject
+	fingerprint, err := fingerprint(oc, subject)
+	if err != nil {
+		return graphql.ErrorResponse(ctx, "Failed to fingerprint request: %s", err.Error())
+	}
+
+	scopedKey := scope(subject, key)
+	e, owner := s.claim(scopedKey, fingerprint)
+	if e.fingerprint != fingerprint {
+		return errorResponse("IDEMPOTENCY_KEY_REUSED", "Idempotency key %q was already used for a different request", key)
+	}
+
+	if !owner {
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return errorResponse("IDEMPOTENCY_KEY_IN_USE", "Idempotency key %q is in use by a request that is still running", key)
+		}
+		if e.response == nil {
+			return errorResponse("IDEMPOTENCY_KEY_IN_USE", "The original request with idempotency key %q didn't finish; retry it", key)
+		}
+
+		return replay(e.response)
+	}
+
+	defer func() {
+		if e.response == nil {
+			s.release(scopedKey, e)
+		}
+		close(e.done)
+	}()
+
+	e.response = next(ctx)
+	return e.response
+}
+
+// claim returns the live entry for the key, creating one owned by the caller if there isn't one.
+func (s *Store) claim(key string, fingerprint string) (*entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range s.entries {
+		if isDone(e) && now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+
+	if e, ok := s.entries[key]; ok {
+		return e, false
+	}
+
+	e := &entry{fingerprint: fingerprint, expires: now.Add(s.Window), done: make(chan struct{})}
+	if s.entries == nil {
+		s.entries = make(map[string]*entry)
+	}
+	s.entries[key] = e
+
+	return e, true
+}
+
+// release forgets the entry for the key, if it's still the given one.
+func (s *Store) release(key string, e *entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries[key] == e {
+		delete(s.entries, key)
+	}
+}
+
+// isDone reports whether the entry's response has been produced.
+func isDone(e *entry) bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// scope returns the key that the caller's idempotency key is stored under.
+func scope(subject string, key string) string {
+	// Header values can't contain a NUL, so the last one always separates the subject from the key.
+	return subject + "\x00" + key
+}
+
+// fingerprint identifies a request by its caller, query, operation name and variables.
+func fingerprint(oc *graphql.OperationContext, subject string) (string, error) {
+	encoded, err := json.Marshal(struct {
+		Subject       string         `json:"subject"`
+		Query         string         `json:"query"`
+		OperationName string         `json:"operationName"`
+		Variables     map[string]any `json:"variables"`
+	}{subject, oc.RawQuery, oc.OperationName, oc.Variables})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// replay returns a copy of a remembered response, marked as a replay.
+func replay(response *graphql.Response) *graphql.Response {
+	replayed := *response
+	replayed.Extensions = map[string]any{ReplayedExtension: true}
+	for k, v := range response.Extensions {
+		replayed.Extensions[k] = v
+	}
+
+	return &replayed
+}
+
+// errorResponse returns a response with a single error with the given code.
+func errorResponse(code string, message string, args ...any) *graphql.Response {
+	err := gqlerror.Errorf(message, args...)
+	err.Extensions = map[string]any{"code": code}
+
+	return &graphql.Response{Errors: gqlerror.List{err}}
+}
@@ -0,0 +1,221 @@
+// Package monitor watches the statuses of alerts in the background and tells subscribers when they change. Each
+// project with subscribers is polled by a single poller, however many subscribers it has.
+package monitor
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/djspinmonkey/lightgraph-go/graph/model"
+	"github.com/djspinmonkey/lightgraph-go/restapi"
+)
+
+const (
+	// DefaultPollInterval is how often a project's alert statuses are polled when no interval is configured.
+	DefaultPollInterval = 30 * time.Second
+
+	// SubscriberBuffer is how many changes can be waiting for a subscriber before further changes are dropped.
+	SubscriberBuffer = 64
+)
+
+// Monitor polls the alert statuses of projects with subscribers, and sends each change to the subscribers it
+// matches. It's safe for concurrent use.
+type Monitor struct {
+	PollInterval time.Duration
+
+	mu       sync.Mutex
+	projects map[projectKey]*project
+}
+
+// projectKey identifies a project.
+type projectKey struct {
+	orgID     string
+	projectID string
+}
+
+// project is the state of a monitored project.
+type project struct {
+	subscribers map[*subscriber]bool
+	// statuses holds the last known status of each alert, by ID. It's nil until the project's first poll.
+	statuses map[string]string
+	stop     chan struct{}
+}
+
+// subscriber is a consumer of a project's status changes.
+type subscriber struct {
+	changes chan *model.AlertStatusChange
+	matches func(*model.Alert) bool
+}
+
+// New returns a monitor that polls each project with subscribers at the given interval.
+func New(pollInterval time.Duration) *Monitor {
+	return &Monitor{PollInterval: pollInterval, projects: make(map[projectKey]*project)}
+}
+
+// Subscribe returns a channel of the status changes of the project's alerts that match the filter, or of all its
+// alerts if the filter is nil. The project is polled for as long as it has subscribers, and the first poll only
+// records the current statuses, so changes are only sent once a subscriber has seen them happen. The channel is
+// closed when the context is done.
+func (m *Monitor) Subscribe(ctx context.Context, orgID string, projectID string, filter func(*model.Alert) bool) <-chan *model.AlertStatusChange {
+	key := projectKey{orgID: orgID, projectID: projectID}
+	sub := &subscriber{changes: make(chan *model.AlertStatusChange, SubscriberBuffer), matches: filter}
+
+	m.mu.Lock()
+	p := m.project(key)
+	p.subscribers[sub] = true
+	if p.stop == nil {
+		p.stop = make(chan struct{})
+		p.statuses = nil
+		go m.poll(key, p.stop)
+	}
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		delete(p.subscribers, sub)
+		close(sub.changes)
+		if len(p.subscribers) == 0 && p.stop != nil {
+			close(p.stop)
+			p.stop = nil
+		}
+	}()
+
+	return sub.changes
+}
+
+// Observe records the current status of an alert, and if it differs from the last status recorded, sends the change
+// to the project's subscribers. Statuses aren't recorded for projects that haven't been polled yet, since there's
+// nothing to compare them with.
+func (m *Monitor) Observe(alert *model.Alert, status string) {
+	key := projectKey{orgID: alert.Project.Organization.ID, projectID: alert.Project.ID}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[key]
+	if !ok || p.statuses == nil {
+		return
+	}
+
+	previous, known := p.statuses[alert.ID]
+	p.statuses[alert.ID] = status
+	if !known || previous == status {
+		return
+	}
+
+	change := &model.AlertStatusChange{Alert: alert, PreviousStatus: previous, Status: status, ChangedAt: time.Now().UTC()}
+	for sub := range p.subscribers {
+		if sub.matches != nil && !sub.matches(alert) {
+			continue
+		}
+
+		select {
+		case sub.changes <- change:
+		default:
+			log.Printf("Dropping status change of alert %s for a subscriber that isn't keeping up", alert.ID)
+		}
+	}
+}
+
+// Watching reports whether the project has subscribers whose statuses have been recorded, so that changes observed
+// in it can be sent to them.
+func (m *Monitor) Watching(orgID string, projectID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[projectKey{orgID: orgID, projectID: projectID}]
+	return ok && len(p.subscribers) > 0 && p.statuses != nil
+}
+
+// project returns the state of the project, creating it if needed. The caller must hold m.mu.
+func (m *Monitor) project(key projectKey) *project {
+	p, ok := m.projects[key]
+	if !ok {
+		p = &project{subscribers: make(map[*subscriber]bool)}
+		if m.projects == nil {
+			m.projects = make(map[projectKey]*project)
+		}
+		m.projects[key] = p
+	}
+
+	return p
+}
+
+// poll polls the project's alert statuses every PollInterval until stop is closed.
+func (m *Monitor) poll(key projectKey, stop chan struct{}) {
+	ticker := time.NewTicker(m.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		m.pollOnce(key, stop)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollOnce fetches the current status of every alert in the project and observes it. The first poll of a project
+// only records the statuses.
+func (m *Monitor) pollOnce(key projectKey, stop chan struct{}) {
+	org := &model.Organization{ID: key.orgID, Name: key.orgID}
+	alerts, err := org.Project(key.projectID).Alerts()
+	if err != nil {
+		log.Printf("Failed to poll alerts of project %s: %s", key.projectID, err.Error())
+		return
+	}
+
+	statuses := make([]string, len(alerts))
+	restapi.Concurrently(len(alerts), func(i int) {
+		status, err := alerts[i].FetchStatus()
+		if err != nil {
+			log.Printf("Failed to poll status of alert %s: %s", alerts[i].ID, err.Error())
+			return
+		}
+		statuses[i] = status
+	})
+
+	m.mu.Lock()
+	p := m.projects[key]
+	if p.stop != stop {
+		// Every subscriber left while we were polling.
+		m.mu.Unlock()
+		return
+	}
+	if p.statuses == nil {
+		p.statuses = make(map[string]string)
+		for i, alert := range alerts {
+			if statuses[i] != "" {
+				p.statuses[alert.ID] = statuses[i]
+			}
+		}
+		m.mu.Unlock()
+		return
+	}
+
+	// Forget alerts that have been deleted, so one recreated with the same ID starts afresh.
+	current := make(map[string]bool)
+	for _, alert := range alerts {
+		current[alert.ID] = true
+	}
+	for id := range p.statuses {
+		if !current[id] {
+			delete(p.statuses, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for i, alert := range alerts {
+		if statuses[i] != "" {
+			m.Observe(alert, statuses[i])
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/djspinmonkey/lightgraph-go/graph/model"
+)
+
+const (
+	// SnoozeCheckInterval is how often a SnoozeTracker checks its tracked snoozes for ones that are about to end or
+	// have ended. Snoozes are refetched from the API less often, every PollInterval.
+	SnoozeCheckInterval = 5 * time.Second
+
+	// DefaultSnoozePollInterval is how often a project's snoozes are fetched when no interval is configured. Fetching
+	// takes a request for each of the project's alerts, and snoozes are checked against their end times between
+	// fetches, so this only limits how soon new and removed snoozes are noticed.
+	DefaultSnoozePollInterval = 5 * time.Minute
+)
+
+// SnoozeTracker tracks the active snoozes of projects with subscribers, and tells subscribers when a snooze is about
+// to end and when it has ended. It's safe for concurrent use.
+type SnoozeTracker struct {
+	// PollInterval is how often each project's snoozes are fetched from the API.
+	PollInterval time.Duration
+
+	mu       sync.Mutex
+	projects map[projectKey]*snoozeProject
+}
+
+// snoozeProject is the state of a project whose snoozes are tracked.
+type snoozeProject struct {
+	subscribers map[*snoozeSubscriber]bool
+	// snoozes holds the project's active snoozes, by alert ID. It's nil until they're first fetched.
+	snoozes map[string]*trackedSnooze
+	stop    chan struct{}
+}
+
+// trackedSnooze is an active snooze, and whether its end has been announced.
+type trackedSnooze struct {
+	expiry  *model.SnoozeExpiry
+	expired bool
+}
+
+// snoozeSubscriber is a consumer of either expiring or expired snooze events for a project.
+type snoozeSubscriber struct {
+	events chan *model.SnoozeExpiry
+	// expired is set for subscribers to ended snoozes, rather than ones about to end.
+	expired bool
+	// within is how long before a snooze ends an expiring subscriber is told about it.
+	within time.Duration
+	// warned holds the end time of the snooze each alert's subscriber was last told about.
+	warned map[string]time.Time
+}
+
+// NewSnoozeTracker returns a tracker that fetches each project's snoozes at the given interval.
+func NewSnoozeTracker(pollInterval time.Duration) *SnoozeTracker {
+	return &SnoozeTracker{PollInterval: pollInterval, projects: make(map[projectKey]*snoozeProject)}
+}
+
+// SubscribeExpiring returns a channel of the project's snoozes that will end within the given duration. Each snooze
+// is sent once, as soon as it's within the duration of ending; snoozes already that close when the subscriber joins
+// are sent right away. The channel is closed when the context is done.
+func (t *SnoozeTracker) SubscribeExpiring(ctx context.Context, orgID string, projectID string, within time.Duration) <-chan *model.SnoozeExpiry {
+	return t.subscribe(ctx, projectKey{orgID: orgID, projectID: projectID}, &snoozeSubscriber{within: within})
+}
+
+// SubscribeExpired returns a channel of the project's snoozes that have ended, either by running out or by being
+// removed after they should have ended. Snoozes removed early by unsnoozing aren't sent. The channel is closed when
+// the context is done.
+func (t *SnoozeTracker) SubscribeExpired(ctx context.Context, orgID string, projectID string) <-chan *model.SnoozeExpiry {
+	return t.subscribe(ctx, projectKey{orgID: orgID, projectID: projectID}, &snoozeSubscriber{expired: true})
+}
+
+// subscribe adds a subscriber to the project, starting to track the project's snoozes if it's the first.
+func (t *SnoozeTracker) subscribe(ctx context.Context, key projectKey, sub *snoozeSubscriber) <-chan *model.SnoozeExpiry {
+	sub.events = make(chan *model.SnoozeExpiry, SubscriberBuffer)
+	sub.warned = make(map[string]time.Time)
+
+	t.mu.Lock()
+	p, ok := t.projects[key]
+	if !ok {
+		p = &snoozeProject{subscribers: make(map[*snoozeSubscriber]bool)}
+		if t.projects == nil {
+			t.projects = make(map[projectKey]*snoozeProject)
+		}
+		t.projects[key] = p
+	}
+	p.subscribers[sub] = true
+	if p.stop == nil {
+		p.stop = make(chan struct{})
+		p.snoozes = nil
+		go t.track(key, p.stop)
+	} else if p.snoozes != nil {
+		t.check(p, time.Now())
+	}
+	t.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		delete(p.subscribers, sub)
+		close(sub.events)
+		if len(p.subscribers) == 0 && p.stop != nil {
+			close(p.stop)
+			p.stop = nil
+		}
+	}()
+
+	return sub.events
+}
+
+// track fetches the project's snoozes every PollInterval, and checks them every SnoozeCheckInterval, until stop is
+// closed.
+func (t *SnoozeTracker) track(key projectKey, stop chan struct{}) {
+	checkInterval := SnoozeCheckInterval
+	if t.PollInterval < checkInterval {
+		checkInterval = t.PollInterval
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	var lastFetch time.Time
+	for {
+		if time.Since(lastFetch) >= t.PollInterval {
+			lastFetch = time.Now()
+			t.fetch(key, stop)
+		}
+
+		t.mu.Lock()
+		if p := t.projects[key]; p.stop == stop && p.snoozes != nil {
+			t.check(p, time.Now())
+		}
+		t.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetch replaces the project's tracked snoozes with its currently active ones. A tracked snooze that's gone after it
+// should have ended is announced as expired, in case it was removed before a check noticed it had ended.
+func (t *SnoozeTracker) fetch(key projectKey, stop chan struct{}) {
+	org := &model.Organization{ID: key.orgID, Name: key.orgID}
+	active, err := model.FetchActiveSnoozes(org.Project(key.projectID))
+	if err != nil {
+		log.Printf("Failed to fetch snoozes of project %s: %s", key.projectID, err.Error())
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.projects[key]
+	if p.stop != stop {
+		// Every subscriber left while we were fetching.
+		return
+	}
+
+	snoozes := make(map[string]*trackedSnooze)
+	for _, expiry := range active {
+		if tracked, ok := p.snoozes[expiry.Alert.ID]; ok && tracked.expiry.EndsAt.Equal(expiry.EndsAt) {
+			snoozes[expiry.Alert.ID] = tracked
+			continue
+		}
+		snoozes[expiry.Alert.ID] = &trackedSnooze{expiry: expiry}
+	}
+
+	now := time.Now()
+	for alertID, tracked := range p.snoozes {
+		if _, ok := snoozes[alertID]; !ok && !tracked.expired && !now.Before(tracked.expiry.EndsAt) {
+			t.send(p, tracked.expiry, func(sub *snoozeSubscriber) bool { return sub.expired })
+		}
+	}
+
+	p.snoozes = snoozes
+}
+
+// check sends expiring and expired events for the project's tracked snoozes. The caller must hold t.mu.
+func (t *SnoozeTracker) check(p *snoozeProject, now time.Time) {
+	for alertID, tracked := range p.snoozes {
+		if !now.Before(tracked.expiry.EndsAt) {
+			if !tracked.expired {
+				tracked.expired = true
+				t.send(p, tracked.expiry, func(sub *snoozeSubscriber) bool { return sub.expired })
+			}
+			continue
+		}
+
+		t.send(p, tracked.expiry, func(sub *snoozeSubscriber) bool {
+			if sub.expired || tracked.expiry.EndsAt.Sub(now) > sub.within || sub.warned[alertID].Equal(tracked.expiry.EndsAt) {
+				return false
+			}
+			sub.warned[alertID] = tracked.expiry.EndsAt
+			return true
+		})
+	}
+}
+
+// send sends the event to each of the project's subscribers that wants it. The caller must hold t.mu.
+func (t *SnoozeTracker) send(p *snoozeProject, expiry *model.SnoozeExpiry, wants func(*snoozeSubscriber) bool) {
+	for sub := range p.subscribers {
+		if !wants(sub) {
+			continue
+		}
+
+		select {
+		case sub.events <- expiry:
+		default:
+			log.Printf("Dropping snooze event of alert %s for a subscriber that isn't keeping up", expiry.Alert.ID)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Package policy decides which organizations and projects each caller may read and write. Since the server reaches
+// the backing API with a single token, this is what keeps teams to their own projects.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"gopkg.in/yaml.v3"
+
+	"github.com/djspinmonkey/lightgraph-go/auth"
+)
+
+// Permission is something a rule lets its subjects do to its resources.
+type Permission string
+
+const (
+	// Read lets subjects see resources. Write implies it.
+	Read Permission = "read"
+	// Write lets subjects change resources with mutations.
+	Write Permission = "write"
+)
+
+// GroupPrefix marks a rule subject that names a group rather than a single caller.
+const GroupPrefix = "group:"
+
+// Rule grants permissions on resources to subjects.
+type Rule struct {
+	// Subjects are the callers the rule applies to: subjects like "alice", groups like "group:sre", or "*" for every
+	// caller, including anonymous ones.
+	Subjects []string `yaml:"subjects"`
+	// Resources are "org/project" patterns, where "*" matches any part of an ID. A pattern without a project, like
+	// "acme", covers every project in the organization.
+	Resources []string `yaml:"resources"`
+	// Permissions are what the rule lets its subjects do.
+	Permissions []Permission `yaml:"permissions"`
+}
+
+// Policy is a list of rules, any of which can grant access. A nil Policy allows everything, for servers that aren't
+// configured with one.
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.FieldInterceptor
+} = &Policy{}
+
+// Load reads a policy from a YAML file, like:
+//
+//	rules:
+//	  - subjects: ["group:payments"]
+//	    resources: ["acme/payments-*"]
+//	    permissions: [read, write]
+func Load(filename string) (*Policy, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.New("Failed to read policy: " + err.Error())
+	}
+
+	var p Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&p)
+	if err != nil {
+		return nil, errors.New("Failed to parse policy: " + err.Error())
+	}
+
+	for i, rule := range p.Rules {
+		if len(rule.Subjects) == 0 || len(rule.Resources) == 0 || len(rule.Permissions) == 0 {
+			return nil, fmt.Errorf("rule %d of policy needs subjects, resources and permissions", i+1)
+		}
+		for _, permission := range rule.Permissions {
+			if permission != Read && permission != Write {
+				return nil, fmt.Errorf("rule %d of policy has unknown permission %q", i+1, permission)
+			}
+		}
+		for _, pattern := range rule.Resources {
+			if _, err := path.Match(resourcePattern(pattern), ""); err != nil || strings.Count(pattern, "/") > 1 {
+				return nil, fmt.Errorf("rule %d of policy has invalid resource %q", i+1, pattern)
+			}
+		}
+	}
+
+	return &p, nil
+}
+
+// Allows reports whether the caller has the permission on the project.
+func (p *Policy) Allows(identity *auth.Identity, permission Permission, orgID string, projectID string) bool {
+	return p.allows(identity, permission, func(pattern string) bool {
+		matched, _ := path.Match(pattern, orgID+"/"+projectID)
+		return matched
+	})
+}
+
+// AllowsOrganization reports whether the caller can read anything in the organization.
+func (p *Policy) AllowsOrganization(identity *auth.Identity, orgID string) bool {
+	return p.allows(identity, Read, func(pattern string) bool {
+		matched, _ := path.Match(strings.SplitN(pattern, "/", 2)[0], orgID)
+		return matched
+	})
+}
+
+// AllowsArguments reports whether the caller has the permission on the project that a field's arguments refer to,
+// the way InterceptField finds it. Arguments that don't refer to a project need the permission on every project.
+func (p *Policy) AllowsArguments(identity *auth.Identity, permission Permission, args map[string]any) bool {
+	orgID, projectID, scoped := projectOf(args)
+	if !scoped {
+		return p.Allows(identity, permission, "*", "*")
+	}
+
+	return p.Allows(identity, permission, orgID, projectID)
+}
+
+// allows reports whether any rule for the caller grants the permission on a resource pattern that matches.
+func (p *Policy) allows(identity *auth.Identity, permission Permission, matches func(pattern string) bool) bool {
+	if p == nil {
+		return true
+	}
+
+	for _, rule := range p.Rules {
+		if !rule.grants(permission) || !rule.appliesTo(identity) {
+			continue
+		}
+		for _, pattern := range rule.Resources {
+			if matches(resourcePattern(pattern)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// grants reports whether the rule grants the permission.
+func (r Rule) grants(permission Permission) bool {
+	for _, p := range r.Permissions {
+		if p == permission || p == Write && permission == Read {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appliesTo reports whether the caller is one of the rule's subjects.
+func (r Rule) appliesTo(identity *auth.Identity) bool {
+	for _, subject := range r.Subjects {
+		if subject == "*" || subject == identity.Subject {
+			return true
+		}
+		if group, ok := strings.CutPrefix(subject, GroupPrefix); ok {
+			for _, g := range identity.Groups {
+				if g == group {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// resourcePattern returns the "org/project" pattern of a rule resource, which may leave out the project.
+func resourcePattern(resource string) string {
+	if !strings.Contains(resource, "/") {
+		return resource + "/*"
+	}
+
+	return resource
+}
+
+// Forbidden returns the error for a caller who lacks access to something.
+func Forbidden(message string, args ...any) *gqlerror.Error {
+	err := gqlerror.Errorf(message, args...)
+	err.Extensions = map[string]any{"code": "FORBIDDEN"}
+
+	return err
+}
+
+// ExtensionName returns the name of the extension.
+func (p *Policy) ExtensionName() string {
+	return "Policy"
+}
+
+// Validate checks that the extension is usable with the schema.
+func (p *Policy) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptField checks the caller's access to the project of each top-level mutation and subscription before
+// resolving it: write access for mutations, and read access for subscriptions. A mutation's project is taken from its
+// orgID and projectID arguments, or from those fields of an input argument, like createIncidentFromAlert's alertRef.
+// Fields that don't act on a particular project are checked against the literal resource "*/*", which only rules
+// for every project, like "*" or "*/*", cover.
+func (p *Policy) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || (fc.Object != "Mutation" && fc.Object != "Subscription") || strings.HasPrefix(fc.Field.Name, "__") {
+		return next(ctx)
+	}
+
+	permission := Write
+	if fc.Object == "Subscription" {
+		permission = Read
+	}
+
+	identity := auth.IdentityFrom(ctx)
+	orgID, projectID, scoped := projectOf(fc.Field.ArgumentMap(graphql.GetOperationContext(ctx).Variables))
+	if !scoped {
+		if !p.Allows(identity, permission, "*", "*") {
+			return nil, Forbidden("%s may not %s %s", identity.Subject, permission, fc.Field.Name)
+		}
+		return next(ctx)
+	}
+
+	if !p.Allows(identity, permission, orgID, projectID) {
+		return nil, Forbidden("%s may not %s project %s in organization %s", identity.Subject, permission, projectID, orgID)
+	}
+
+	return next(ctx)
+}
+
+// projectOf finds the organization and project a field's arguments refer to, and whether they refer to one.
+func projectOf(args map[string]any) (string, string, bool) {
+	if orgID, projectID, ok := orgAndProject(args); ok {
+		return orgID, projectID, true
+	}
+
+	for _, arg := range args {
+		if input, ok := arg.(map[string]any); ok {
+			if orgID, projectID, ok := orgAndProject(input); ok {
+				return orgID, projectID, true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+// orgAndProject returns the orgID and projectID values of a map of arguments, and whether it has both.
+func orgAndProject(values map[string]any) (string, string, bool) {
+	orgID, orgOK := values["orgID"].(string)
+	projectID, projectOK := values["projectID"].(string)
+
+	return orgID, projectID, orgOK && projectOK
+}
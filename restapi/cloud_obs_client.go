@@ -1,12 +1,20 @@
 package restapi
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
 )
 
+// ErrPreconditionFailed is returned for a conditional request when the resource has changed since the version it was
+// conditional on.
+var ErrPreconditionFailed = errors.New("REST API returned status: 412 Precondition Failed")
+
 // CloudObsApiKey retrieves the API key from the environment.
 func CloudObsApiKey() string {
 	key := os.Getenv("LS_TOKEN")
@@ -29,22 +37,95 @@ func CloudObsBaseUrl() string {
 
 // GetCloudObsResource submits a GET request to the Cloud Obs REST API at the given path, using the configured base URL and API key.
 func GetCloudObsResource(path string) (*http.Response, error) {
+	return sendCloudObsRequest(context.Background(), "GET", path, nil, nil)
+}
+
+// PostCloudObsResource submits a POST request with the given body, encoded as JSON, to the Cloud Obs REST API at the
+// given path, using the configured base URL and API key. It's for read-only queries that happen to need a request
+// body; use CreateCloudObsResource to create things.
+func PostCloudObsResource(path string, body any) (*http.Response, error) {
+	return sendCloudObsRequest(context.Background(), "POST", path, body, nil)
+}
+
+// CreateCloudObsResource submits a POST request with the given body, encoded as JSON, to create a resource at the
+// given path in the Cloud Obs REST API.
+func CreateCloudObsResource(ctx context.Context, path string, body any) (*http.Response, error) {
+	return sendCloudObsRequest(ctx, "POST", path, body, nil)
+}
+
+// UpdateCloudObsResource submits a PUT request with the given body, encoded as JSON, to replace the resource at the
+// given path in the Cloud Obs REST API.
+func UpdateCloudObsResource(ctx context.Context, path string, body any) (*http.Response, error) {
+	return sendCloudObsRequest(ctx, "PUT", path, body, nil)
+}
+
+// UpdateCloudObsResourceIfMatch is like UpdateCloudObsResource, but if the ETag isn't empty, the resource is only
+// replaced if it still has that ETag. If it doesn't, ErrPreconditionFailed is returned.
+func UpdateCloudObsResourceIfMatch(ctx context.Context, path string, etag string, body any) (*http.Response, error) {
+	var header http.Header
+	if etag != "" {
+		header = http.Header{"If-Match": []string{etag}}
+	}
+
+	return sendCloudObsRequest(ctx, "PUT", path, body, header)
+}
+
+// DeleteCloudObsResource submits a DELETE request for the resource at the given path in the Cloud Obs REST API.
+func DeleteCloudObsResource(ctx context.Context, path string) (*http.Response, error) {
+	return sendCloudObsRequest(ctx, "DELETE", path, nil, nil)
+}
+
+// sendCloudObsRequest submits a request to the Cloud Obs REST API, with the body encoded as JSON if there is one and
+// any extra headers, and returns the response if it was successful.
+func sendCloudObsRequest(ctx context.Context, method string, path string, body any, header http.Header) (*http.Response, error) {
 	url := CloudObsBaseUrl() + path
-	fmt.Printf("\n******* requesting resource: %s\n", url) // debugging output
+	log.Printf("requesting resource: %s %s", method, url)
+
+	var encoded []byte
+	if body != nil {
+		var err error
+		encoded, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun := dryRunFrom(ctx); dryRun != nil && method != "GET" {
+		resp := dryRun.record("cloudobs", method, path, encoded, encoded)
+		logWrite(ctx, "cloudobs", method, path, resp)
+		return resp, nil
+	}
+
+	var bodyReader io.Reader
+	if encoded != nil {
+		bodyReader = bytes.NewReader(encoded)
+	}
 
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Add("User-Agent", "lightgraph-go")
 	req.Header.Add("Authorization", CloudObsApiKey())
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
 
 	resp, err := client.Do(req)
+	logWrite(ctx, "cloudobs", method, path, resp)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != 200 {
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return nil, ErrPreconditionFailed
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return nil, errors.New("REST API returned status: " + resp.Status)
 	}
 
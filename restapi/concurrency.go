@@ -0,0 +1,25 @@
+package restapi
+
+import "sync"
+
+// MaxConcurrentRequests is the most requests a single operation, like a bulk update or a poll of a project's alert
+// statuses, sends to the backing APIs at once.
+const MaxConcurrentRequests = 8
+
+// Concurrently calls do once for each index from 0 to n-1, at most MaxConcurrentRequests calls at a time, and returns
+// once every call has returned. Each call should make at most one request at a time.
+func Concurrently(n int, do func(i int)) {
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, MaxConcurrentRequests)
+	for i := 0; i < n; i++ {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			do(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,154 @@
+package restapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Redacted replaces secret values, like destination integration keys, in the bodies of planned requests.
+const Redacted = "[REDACTED]"
+
+// secretFields are the names of body fields whose values are secret, wherever they appear.
+var secretFields = map[string]bool{
+	"integration_key": true,
+	"password":        true,
+	"secret":          true,
+	"token":           true,
+}
+
+// secretMaps are the names of body fields holding maps whose values are all secret, like ServiceNow destinations'
+// auth values and webhook destinations' custom headers.
+var secretMaps = map[string]bool{
+	"auth":           true,
+	"custom_headers": true,
+}
+
+// PlannedRequest is a write request that was recorded during a dry run instead of being sent.
+type PlannedRequest struct {
+	// Service is the API the request is for: "cloudobs" or "servicenow".
+	Service string `json:"service"`
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	// Body is the JSON body of the request, with secret values replaced by Redacted.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// DryRun records the write requests made with a dry-run context. It's safe for concurrent use.
+type DryRun struct {
+	mu       sync.Mutex
+	requests []*PlannedRequest
+}
+
+type dryRunKey struct{}
+
+// WithDryRun returns a context in which write requests to the Cloud Obs and ServiceNow APIs are recorded in the
+// returned DryRun rather than sent. GET requests, and read-only requests like PostCloudObsResource, are still sent,
+// so that writes can be validated against the current state of things.
+//
+// A recorded request gets a successful response that echoes its body back, wrapped in a "result" object for
+// ServiceNow, so code that parses the response sees roughly what it would have created. Anything the API would
+// have filled in, like IDs, is missing.
+func WithDryRun(ctx context.Context) (context.Context, *DryRun) {
+	dryRun := &DryRun{requests: []*PlannedRequest{}}
+	return context.WithValue(ctx, dryRunKey{}, dryRun), dryRun
+}
+
+// IsDryRun reports whether write requests made with the context are recorded rather than sent.
+func IsDryRun(ctx context.Context) bool {
+	return dryRunFrom(ctx) != nil
+}
+
+// Requests returns the requests recorded so far, in the order they were made.
+func (d *DryRun) Requests() []*PlannedRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]*PlannedRequest{}, d.requests...)
+}
+
+// dryRunFrom returns the DryRun the context records requests in, or nil if it isn't a dry-run context.
+func dryRunFrom(ctx context.Context) *DryRun {
+	dryRun, _ := ctx.Value(dryRunKey{}).(*DryRun)
+	return dryRun
+}
+
+// redactBody returns a copy of a JSON request body with secret values replaced by Redacted, so that dry-run reports
+// don't give away secrets that a write would have sent, such as those an update carries over from the existing
+// destination. Bodies that aren't JSON objects are returned as they are.
+func redactBody(body []byte) []byte {
+	var decoded map[string]any
+	if json.Unmarshal(body, &decoded) != nil {
+		return body
+	}
+
+	redacted, err := json.Marshal(redactValue(decoded))
+	if err != nil {
+		return body
+	}
+
+	return redacted
+}
+
+// redactValue returns a copy of a decoded JSON value with secret values replaced by Redacted.
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for key, child := range v {
+			switch {
+			case secretFields[key] && child != nil && child != "":
+				redacted[key] = Redacted
+			case secretMaps[key]:
+				redacted[key] = redactMap(child)
+			default:
+				redacted[key] = redactValue(child)
+			}
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, child := range v {
+			redacted[i] = redactValue(child)
+		}
+		return redacted
+	default:
+		return v
+	}
+}
+
+// redactMap returns a copy of a decoded JSON object with every value replaced by Redacted, keeping its keys.
+func redactMap(value any) any {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return value
+	}
+
+	redacted := make(map[string]any, len(m))
+	for key := range m {
+		redacted[key] = Redacted
+	}
+
+	return redacted
+}
+
+// record adds a request to the dry run and returns the response standing in for the real one.
+func (d *DryRun) record(service string, method string, path string, body []byte, responseBody []byte) *http.Response {
+	d.mu.Lock()
+	d.requests = append(d.requests, &PlannedRequest{Service: service, Method: method, Path: path, Body: redactBody(body)})
+	d.mu.Unlock()
+
+	if responseBody == nil {
+		responseBody = []byte("{}")
+	}
+
+	return &http.Response{
+		Status:     "200 OK (dry run)",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(responseBody)),
+	}
+}
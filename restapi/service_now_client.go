@@ -1,8 +1,12 @@
 package restapi
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
+	"log"
 	"net/http"
 )
 
@@ -27,25 +31,65 @@ func ServiceNowBaseURL() string {
 
 // GetServiceNowResource submits a GET request to the ServiceNow API at the given path, using the (currently hardcoded) base URL and API key.
 func GetServiceNowResource(path string) (*http.Response, error) {
+	return sendServiceNowRequest(context.Background(), "GET", path, nil)
+}
+
+// CreateServiceNowResource submits a POST request with the given body, encoded as JSON, to create a record at the
+// given path in the ServiceNow API.
+func CreateServiceNowResource(ctx context.Context, path string, body any) (*http.Response, error) {
+	return sendServiceNowRequest(ctx, "POST", path, body)
+}
+
+// sendServiceNowRequest submits a request to the ServiceNow API, with the body encoded as JSON if there is one, and
+// returns the response if it was successful.
+func sendServiceNowRequest(ctx context.Context, method string, path string, body any) (*http.Response, error) {
 	url := ServiceNowBaseURL() + path
-	fmt.Printf("\n******* requesting resource: %s\n", url) // debugging output
+	log.Printf("requesting resource: %s %s", method, url)
+
+	var encoded []byte
+	if body != nil {
+		var err error
+		encoded, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun := dryRunFrom(ctx); dryRun != nil && method != "GET" {
+		var echo []byte
+		if encoded != nil {
+			echo = []byte(`{"result":` + string(encoded) + `}`)
+		}
+		resp := dryRun.record("servicenow", method, path, encoded, echo)
+		logWrite(ctx, "servicenow", method, path, resp)
+		return resp, nil
+	}
+
+	var bodyReader io.Reader
+	if encoded != nil {
+		bodyReader = bytes.NewReader(encoded)
+	}
 
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Add("User-Agent", "lightgraph-go")
 	req.Header.Add("Accept", "application/json")
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
 
 	creds := ServiceNowLoginCredentials()
 	req.SetBasicAuth(creds.Username, creds.Password)
 
 	resp, err := client.Do(req)
+	logWrite(ctx, "servicenow", method, path, resp)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != 200 {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return nil, errors.New("ServiceNow API returned status: " + resp.Status)
 	}
 
@@ -0,0 +1,98 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ServiceNowDateTime is the layout of date-time values in the ServiceNow API. Raw values are always in UTC.
+const ServiceNowDateTime = "2006-01-02 15:04:05"
+
+// ServiceNowTableQuery describes a query against a table in the ServiceNow Table API.
+type ServiceNowTableQuery struct {
+	Table string
+	// Query is a ServiceNow encoded query, like "cmdb_ci=abc123^active=true".
+	Query  string
+	Fields []string
+	// DisplayValues requests both the raw value and the display value of every field, as a ServiceNowField.
+	DisplayValues bool
+	Limit         int
+}
+
+// ServiceNowField is a single field of a record returned by the Table API when display values are requested.
+type ServiceNowField struct {
+	Value        string `json:"value"`
+	DisplayValue string `json:"display_value"`
+}
+
+// UnmarshalJSON accepts either a value/display_value object or a bare value, which the Table API returns when display
+// values aren't requested.
+func (f *ServiceNowField) UnmarshalJSON(data []byte) error {
+	var value string
+	if json.Unmarshal(data, &value) == nil {
+		*f = ServiceNowField{Value: value, DisplayValue: value}
+		return nil
+	}
+
+	type field ServiceNowField
+	return json.Unmarshal(data, (*field)(f))
+}
+
+// Path returns the Table API path, including the query string, for the query.
+func (q ServiceNowTableQuery) Path() string {
+	params := url.Values{}
+	if q.Query != "" {
+		params.Set("sysparm_query", q.Query)
+	}
+	if len(q.Fields) > 0 {
+		params.Set("sysparm_fields", strings.Join(q.Fields, ","))
+	}
+	if q.DisplayValues {
+		params.Set("sysparm_display_value", "all")
+	}
+	if q.Limit > 0 {
+		params.Set("sysparm_limit", strconv.Itoa(q.Limit))
+	}
+	params.Set("sysparm_exclude_reference_link", "true")
+
+	return "/api/now/table/" + q.Table + "?" + params.Encode()
+}
+
+// CheckQueryValue returns an error if the value can't be embedded in an encoded query as it is. The Table API has no
+// way to escape "^", which separates conditions, so a value containing one could add conditions of its own, like
+// "^NQ" to match any record.
+func CheckQueryValue(value string) error {
+	if strings.Contains(value, "^") {
+		return errors.New(`value contains "^", which isn't allowed in a ServiceNow query`)
+	}
+
+	return nil
+}
+
+// QueryServiceNowTable submits a GET request for the records in a ServiceNow table that match the query.
+func QueryServiceNowTable(q ServiceNowTableQuery) (*http.Response, error) {
+	return GetServiceNowResource(q.Path())
+}
+
+// ServiceNowRecordURL returns a link to a record in the ServiceNow UI.
+func ServiceNowRecordURL(table string, sysID string) string {
+	return ServiceNowBaseURL() + "/nav_to.do?uri=" + url.QueryEscape(table+".do?sys_id="+sysID)
+}
+
+// CreateServiceNowRecord submits a POST request to insert a record into a ServiceNow table. The response contains the
+// new record's fields, with display values, limited to the given fields if any are given.
+func CreateServiceNowRecord(ctx context.Context, table string, record any, fields []string) (*http.Response, error) {
+	params := url.Values{}
+	if len(fields) > 0 {
+		params.Set("sysparm_fields", strings.Join(fields, ","))
+	}
+	params.Set("sysparm_display_value", "all")
+	params.Set("sysparm_exclude_reference_link", "true")
+
+	return CreateServiceNowResource(ctx, "/api/now/table/"+table+"?"+params.Encode(), record)
+}
@@ -0,0 +1,60 @@
+package restapi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// Write describes a write request made to the Cloud Obs or ServiceNow API.
+type Write struct {
+	// Service is the API the request was for: "cloudobs" or "servicenow".
+	Service string `json:"service"`
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	// Status is the HTTP status code of the response, or 0 if no response was received.
+	Status int `json:"status"`
+	// DryRun is true if the request was recorded by a dry run rather than sent.
+	DryRun bool `json:"dryRun"`
+}
+
+// WriteLog records the write requests made with a context. It's safe for concurrent use.
+type WriteLog struct {
+	mu     sync.Mutex
+	writes []*Write
+}
+
+type writeLogKey struct{}
+
+// WithWriteLog returns a context in which every write request to the Cloud Obs and ServiceNow APIs, along with its
+// response status, is recorded in the returned WriteLog. GET requests and read-only requests aren't recorded.
+func WithWriteLog(ctx context.Context) (context.Context, *WriteLog) {
+	writeLog := &WriteLog{writes: []*Write{}}
+	return context.WithValue(ctx, writeLogKey{}, writeLog), writeLog
+}
+
+// Writes returns the writes recorded so far, in the order they were made.
+func (l *WriteLog) Writes() []*Write {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]*Write{}, l.writes...)
+}
+
+// logWrite records a write request in the context's WriteLog, if it has one. The response is nil if none was
+// received.
+func logWrite(ctx context.Context, service string, method string, path string, resp *http.Response) {
+	writeLog, _ := ctx.Value(writeLogKey{}).(*WriteLog)
+	if writeLog == nil || method == "GET" {
+		return
+	}
+
+	write := &Write{Service: service, Method: method, Path: path, DryRun: dryRunFrom(ctx) != nil}
+	if resp != nil {
+		write.Status = resp.StatusCode
+	}
+
+	writeLog.mu.Lock()
+	writeLog.writes = append(writeLog.writes, write)
+	writeLog.mu.Unlock()
+}
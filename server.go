@@ -4,10 +4,24 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/djspinmonkey/lightgraph-go/audit"
+	"github.com/djspinmonkey/lightgraph-go/auth"
+	"github.com/djspinmonkey/lightgraph-go/cors"
 	"github.com/djspinmonkey/lightgraph-go/graph"
+	"github.com/djspinmonkey/lightgraph-go/graph/model"
+	"github.com/djspinmonkey/lightgraph-go/idempotency"
+	"github.com/djspinmonkey/lightgraph-go/monitor"
+	"github.com/djspinmonkey/lightgraph-go/policy"
+	"github.com/djspinmonkey/lightgraph-go/webhook"
+	"github.com/gorilla/websocket"
 )
 
 const defaultPort = "8080"
@@ -18,20 +32,144 @@ func main() {
 		port = defaultPort
 	}
 
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}}))
+	if spec := os.Getenv("LS_CI_ASSOCIATION"); spec != "" {
+		strategies, err := model.ParseCIAssociationStrategies(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		model.CIAssociationStrategies = strategies
+	}
+
+	idempotencyWindow := idempotency.DefaultWindow
+	if window := os.Getenv("LS_IDEMPOTENCY_WINDOW"); window != "" {
+		var err error
+		idempotencyWindow, err = time.ParseDuration(window)
+		if err != nil {
+			log.Fatal("invalid $LS_IDEMPOTENCY_WINDOW: " + err.Error())
+		}
+	}
+	idempotencyStore := idempotency.NewStore(idempotencyWindow)
+
+	pollInterval := monitor.DefaultPollInterval
+	if interval := os.Getenv("LS_ALERT_POLL_INTERVAL"); interval != "" {
+		var err error
+		pollInterval, err = time.ParseDuration(interval)
+		if err != nil || pollInterval <= 0 {
+			log.Fatalf("invalid $LS_ALERT_POLL_INTERVAL: %q", interval)
+		}
+	}
+	alertMonitor := monitor.New(pollInterval)
+
+	snoozePollInterval := monitor.DefaultSnoozePollInterval
+	if interval := os.Getenv("LS_SNOOZE_POLL_INTERVAL"); interval != "" {
+		var err error
+		snoozePollInterval, err = time.ParseDuration(interval)
+		if err != nil || snoozePollInterval <= 0 {
+			log.Fatalf("invalid $LS_SNOOZE_POLL_INTERVAL: %q", interval)
+		}
+	}
+	snoozeTracker := monitor.NewSnoozeTracker(snoozePollInterval)
+
+	authentication := &auth.Authentication{}
+	if source := os.Getenv("LS_AUTH_JWKS"); source != "" {
+		jwks, err := auth.LoadJWKS(source)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authentication.Authenticators = append(authentication.Authenticators, &auth.JWT{
+			JWKS:     jwks,
+			Issuer:   os.Getenv("LS_AUTH_ISSUER"),
+			Audience: os.Getenv("LS_AUTH_AUDIENCE"),
+		})
+	}
+	if path := os.Getenv("LS_API_KEYS"); path != "" {
+		apiKeys, err := auth.LoadAPIKeys(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authentication.Authenticators = append(authentication.Authenticators, apiKeys)
+	}
+	if !authentication.Required() {
+		log.Printf("Neither $LS_AUTH_JWKS nor $LS_API_KEYS is set, so /query is open to anonymous callers")
+	}
+
+	var accessPolicy *policy.Policy
+	if filename := os.Getenv("LS_POLICY"); filename != "" {
+		var err error
+		accessPolicy, err = policy.Load(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	corsPolicy, err := cors.New(splitList(os.Getenv("LS_CORS_ORIGINS")), os.Getenv("LS_CORS_CREDENTIALS") == "true")
+	if err != nil {
+		log.Fatal("invalid $LS_CORS_ORIGINS: " + err.Error())
+	}
+	if headers := splitList(os.Getenv("LS_CORS_HEADERS")); len(headers) > 0 {
+		corsPolicy.Headers = headers
+	}
+	if methods := splitList(os.Getenv("LS_CORS_METHODS")); len(methods) > 0 {
+		corsPolicy.Methods = methods
+	}
+	if maxAge := os.Getenv("LS_CORS_MAX_AGE"); maxAge != "" {
+		corsPolicy.MaxAge, err = time.ParseDuration(maxAge)
+		if err != nil {
+			log.Fatal("invalid $LS_CORS_MAX_AGE: " + err.Error())
+		}
+	}
+
+	auditLog := &audit.Log{Sink: audit.SinkFromSpec(os.Getenv("LS_AUDIT_LOG"))}
+
+	// This is handler.NewDefaultServer, except that websocket connections are authenticated as they're opened, and
+	// accepted from the origins the CORS policy allows.
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers:  &graph.Resolver{AuditLog: auditLog, Monitor: alertMonitor, Snoozes: snoozeTracker, Policy: accessPolicy},
+		Directives: graph.DirectiveRoot{DryRun: graph.DryRun, Sensitive: graph.Sensitive},
+	}))
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              authentication.WebsocketInit,
+		Upgrader:              websocket.Upgrader{CheckOrigin: corsPolicy.CheckOrigin},
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+	srv.SetQueryCache(lru.New(1000))
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New(100)})
+	srv.Use(graph.ProjectCache{})
 
-	http.Handle("/", handleCors(playground.Handler("SNCO GraphiQL", "/query")))
-	http.Handle("/query", handleCors(srv))
+	// The audit log goes before the idempotency store, so that it sees, and records, the responses the store replays.
+	srv.Use(auditLog)
+	srv.Use(idempotencyStore)
+	if accessPolicy != nil {
+		// Checked after the audit log, so that denied mutations are recorded too.
+		srv.Use(accessPolicy)
+	}
+
+	http.Handle("/", corsPolicy.Middleware(playground.Handler("SNCO GraphiQL", "/query")))
+	http.Handle("/query", corsPolicy.Middleware(authentication.Middleware(idempotencyStore.Middleware(srv))))
+
+	if secret := os.Getenv("LS_WEBHOOK_SECRET"); secret != "" {
+		http.Handle("/webhooks/cloudobs", &webhook.CloudObsHandler{Secret: []byte(secret), Monitor: alertMonitor})
+	} else {
+		log.Printf("$LS_WEBHOOK_SECRET isn't set, so /webhooks/cloudobs is disabled")
+	}
 
 	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
 
-// handleCors is a middleware function that adds CORS headers to the response.
-func handleCors(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "*")
-		next.ServeHTTP(w, r)
-	})
+// splitList splits a comma-separated list from the environment, dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
 }
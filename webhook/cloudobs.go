@@ -0,0 +1,119 @@
+// Package webhook receives notifications pushed by the backing APIs.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djspinmonkey/lightgraph-go/graph/model"
+	"github.com/djspinmonkey/lightgraph-go/monitor"
+)
+
+const (
+	// SecretHeader is the request header that carries the shared secret. Cloud Obs webhook destinations can only add
+	// static custom headers to their requests, so the secret itself is sent, rather than a signature, and the
+	// destination's URL should use https.
+	SecretHeader = "X-Lightgraph-Secret"
+
+	// MaxNotificationSize is the largest notification body accepted, in bytes.
+	MaxNotificationSize = 1 << 20
+)
+
+// JsonShapedAlertNotification is an intermediate representation of the JSON body of an alert notification. A
+// webhook destination with a body template that produces this shape can point at the CloudObsHandler.
+type JsonShapedAlertNotification struct {
+	OrganizationID string `json:"organization-id"`
+	ProjectID      string `json:"project-id"`
+	AlertID        string `json:"alert-id"`
+	Status         string `json:"status"`
+}
+
+// CloudObsHandler receives alert notifications from Cloud Obs webhook destinations. Each notification's status is
+// cached, so Alert.status returns it without asking the API, and is sent to alertStatusChanged subscribers.
+type CloudObsHandler struct {
+	// Secret is the shared secret notifications carry in their SecretHeader.
+	Secret []byte
+
+	Monitor *monitor.Monitor
+}
+
+// ServeHTTP verifies and applies a single alert notification.
+func (h *CloudObsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "notifications must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxNotificationSize))
+	if err != nil {
+		http.Error(w, "Failed to read notification: "+err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if !h.validSecret(r.Header.Get(SecretHeader)) {
+		http.Error(w, "missing or invalid "+SecretHeader, http.StatusUnauthorized)
+		return
+	}
+	receivedAt := time.Now()
+
+	var notification JsonShapedAlertNotification
+	err = json.Unmarshal(body, &notification)
+	if err != nil {
+		http.Error(w, "Failed to parse notification: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if notification.OrganizationID == "" || notification.ProjectID == "" || notification.AlertID == "" || notification.Status == "" {
+		http.Error(w, "organization-id, project-id, alert-id and status are required", http.StatusBadRequest)
+		return
+	}
+
+	err = h.apply(notification, receivedAt)
+	if err != nil {
+		log.Printf("Failed to apply notification for alert %s: %s", notification.AlertID, err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validSecret reports whether the secret is the shared secret. Both are hashed first, so that the comparison takes
+// the same time whatever the secret's length.
+func (h *CloudObsHandler) validSecret(secret string) bool {
+	got := sha256.Sum256([]byte(secret))
+	want := sha256.Sum256(h.Secret)
+	return secret != "" && hmac.Equal(got[:], want[:])
+}
+
+// apply caches the notification's status and, if anyone is subscribed to the alert's project, sends it to them.
+// Notifications received before the last one applied for the alert, but applied after it, are ignored, since their
+// status is out of date.
+func (h *CloudObsHandler) apply(notification JsonShapedAlertNotification, receivedAt time.Time) error {
+	if !model.CacheAlertStatus(notification.OrganizationID, notification.ProjectID, notification.AlertID, notification.Status, receivedAt) {
+		log.Printf("Ignoring out-of-date notification for alert %s", notification.AlertID)
+		return nil
+	}
+
+	if h.Monitor == nil || !h.Monitor.Watching(notification.OrganizationID, notification.ProjectID) {
+		return nil
+	}
+
+	org := &model.Organization{ID: notification.OrganizationID, Name: notification.OrganizationID}
+	alert, err := org.Project(notification.ProjectID).Alert(notification.AlertID)
+	if err != nil {
+		return err
+	}
+	if alert == nil {
+		return fmt.Errorf("alert %s not found in project %s", notification.AlertID, notification.ProjectID)
+	}
+
+	h.Monitor.Observe(alert, notification.Status)
+	return nil
+}